@@ -0,0 +1,74 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Alpha handling. By default, the images are converted as is: transparent
+// pixels get their premultiplied color, i.e. black, the same as compositing
+// them over a transparent black background.
+// Setting Options.Background composites the image over it first, while
+// Options.AlphaCutoff removes the dots of the pixels more transparent than
+// it, regardless of their color.
+
+// hasAlphaOptions checks if the given options change how the alpha is handled.
+func (o *Options) hasAlphaOptions() bool {
+	return o.Background != nil || o.AlphaCutoff != 0
+}
+
+// convertAlpha converts the given image applying the alpha options.
+func convertAlpha(img image.Image, o *Options) *Gray {
+	src := img
+	if o.Background != nil {
+		rgba := image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Rect, image.NewUniform(o.Background), image.Point{}, draw.Src)
+		draw.Draw(rgba, rgba.Rect, img, img.Bounds().Min, draw.Over)
+		src = rgba
+	}
+	opts := *o
+	opts.Background, opts.AlphaCutoff = nil, 0
+	g := ConvertWithOptions(src, &opts)
+
+	if o.AlphaCutoff != 0 {
+		empty := g.Threshold.dotColor(false)
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if alphaAt(img, x, y) < o.AlphaCutoff {
+					g.Gray.SetGray(x, y, empty)
+					g.setDot(x, y, false)
+				}
+			}
+		}
+	}
+	return g
+}
+
+// alphaAt returns the 8 bits alpha of the given pixel.
+func alphaAt(img image.Image, x, y int) uint8 {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return img.Pix[img.PixOffset(x, y)+3]
+	case *image.NRGBA:
+		return img.Pix[img.PixOffset(x, y)+3]
+	}
+	_, _, _, a := img.At(x, y).RGBA()
+	return uint8(a >> 8)
+}
+
+// over returns the color c composited over bg, quantized to 8 bits like
+// draw.Draw does with the draw.Over operator on a *image.RGBA filled with bg.
+func over(c, bg color.Color) color.RGBA {
+	br, bgg, bb, ba := bg.RGBA()
+	sr, sg, sb, sa := c.RGBA()
+	const m = 1<<16 - 1
+	a := (m - sa) * 0x101
+	return color.RGBA{
+		R: uint8(((br>>8)*a/m + sr) >> 8),
+		G: uint8(((bgg>>8)*a/m + sg) >> 8),
+		B: uint8(((bb>>8)*a/m + sb) >> 8),
+		A: uint8(((ba>>8)*a/m + sa) >> 8),
+	}
+}
@@ -0,0 +1,86 @@
+package bug
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// newLogoImage returns a transparent image with an opaque dark square
+// and a semi transparent one.
+func newLogoImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(img, image.Rect(0, 0, 4, 8), image.NewUniform(color.NRGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(4, 0, 8, 4), image.NewUniform(color.NRGBA{R: 0x20, G: 0x20, B: 0x20, A: 0x40}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestConvertAlpha(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		opts   *Options
+		expect string
+	}{
+		// Transparent pixels are black by default.
+		{"default", &Options{Threshold: DefaultThreshold}, "⣿⣿⣿⣿\n⣿⣿⣿⣿\n"},
+		{"white", &Options{Threshold: DefaultThreshold, Background: color.White}, "⣿⣿⠀⠀\n⣿⣿⠀⠀\n"},
+		{"black inverse", &Options{Threshold: DefaultThreshold.Inverse(), Background: color.Black}, "⠀⠀⠀⠀\n⠀⠀⠀⠀\n"},
+		{"gray", &Options{Threshold: DefaultThreshold, Background: color.Gray{Y: 0x70}}, "⣿⣿⣿⣿\n⣿⣿⠀⠀\n"},
+		{"cutoff", &Options{Threshold: DefaultThreshold, AlphaCutoff: 0x80}, "⣿⣿⠀⠀\n⣿⣿⠀⠀\n"},
+		{"cutoff inverse", &Options{Threshold: DefaultThreshold.Inverse(), AlphaCutoff: 0x20}, "⠀⠀⠀⠀\n⠀⠀⠀⠀\n"},
+		{"cutoff low", &Options{Threshold: DefaultThreshold, AlphaCutoff: 0x20}, "⣿⣿⣿⣿\n⣿⣿⠀⠀\n"},
+		{"cutoff classifier", &Options{Threshold: DefaultThreshold, Classifier: ChannelRange{ChannelRed, 0, 0xff}, AlphaCutoff: 0x20}, "⣿⣿⣿⣿\n⣿⣿⠀⠀\n"},
+		{"white edges", &Options{Threshold: DefaultThreshold, Mode: ModeEdges, Background: color.White}, "⠀⠀⡇⠀\n⠀⠀⡇⠀\n"},
+	} {
+		img := ConvertWithOptions(newLogoImage(), tt.opts)
+		assertEqual(t, tt.expect, encodeString(t, img), "Unexpected %s conversion.", tt.name)
+		assertAgree(t, img, "Gray after %s conversion.", tt.name)
+	}
+
+	// BUG images have no transparency.
+	g := Convert(newLogoImage(), DefaultThreshold)
+	assertEqual(t, "⣿⣿⣿⣿\n⣿⣿⣿⣿\n", encodeString(t, ConvertWithOptions(g, &Options{Threshold: DefaultThreshold, Background: color.White})), "Unexpected BUG image conversion.")
+}
+
+// Test the encoder's alpha options, streaming or not, against the conversion.
+func TestEncodeAlpha(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	nrgba := image.NewNRGBA(image.Rect(-3, 1, 37, 23))
+	rnd.Read(nrgba.Pix)
+	rgba := image.NewRGBA(nrgba.Rect)
+	draw.Draw(rgba, rgba.Rect, nrgba, nrgba.Rect.Min, draw.Src)
+	paletted := image.NewPaletted(nrgba.Rect, append(color.Palette{color.Transparent}, palette.Plan9[:128]...))
+	draw.Draw(paletted, paletted.Rect, nrgba, nrgba.Rect.Min, draw.Src)
+
+	for name, src := range map[string]image.Image{"nrgba": nrgba, "rgba": rgba, "paletted": paletted} {
+		for _, o := range []*Options{
+			{Threshold: DefaultThreshold, Background: color.White},
+			{Threshold: -80, Background: color.NRGBA{R: 0xff, A: 0x80}},
+			{Threshold: DefaultThreshold, AlphaCutoff: 0x80},
+			{Threshold: 150, Background: color.Gray{Y: 0x40}, AlphaCutoff: 0x10},
+			{Background: color.White, Classifier: HSVRange{HueMin: 0, HueMax: 180, SatMin: 0.3}},
+		} {
+			expect := encodeString(t, ConvertWithOptions(src, o))
+
+			enc := NewEncoder(nil)
+			enc.Threshold, enc.Classifier, enc.Background, enc.AlphaCutoff = o.Threshold, o.Classifier, o.Background, o.AlphaCutoff
+			for _, stream := range []bool{false, true} {
+				buf := bytes.NewBuffer(nil)
+				enc.w = buf
+				if stream {
+					requireNoError(t, enc.EncodeContext(context.Background(), src), "EncodeContext.")
+				} else {
+					requireNoError(t, enc.Encode(src), "Encode.")
+				}
+				if buf.String() != expect {
+					t.Errorf("Unexpected %s encoding (stream %t) with %+v.", name, stream, o)
+				}
+			}
+		}
+	}
+}
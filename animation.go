@@ -0,0 +1,342 @@
+package bug
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Animation represents a sequence of BUG images.
+// Mirrors the stdlib's gif.GIF.
+type Animation struct {
+	Image []*Gray // The successive images.
+	Delay []int   // The successive delay times, one per frame, in 100ths of a second.
+
+	// LoopCount controls the number of times an animation will be
+	// restarted during display.
+	// A LoopCount of 0 means to loop forever.
+	// A LoopCount of -1 means to show each frame only once.
+	// Otherwise, the animation is looped LoopCount+1 times.
+	LoopCount int
+}
+
+// Text variant.
+//
+// Each frame is written as a regular BUG image followed by a directive
+// line holding the frame delay, e.g. ";delay=10".
+// When the loop count is not 0, a final ";loop=N" directive is added.
+// As the first frame comes first, the stdlib's image.Decode still
+// recognizes the format and returns it.
+const (
+	directivePrefix = ';'
+	delayDirective  = "delay"
+	loopDirective   = "loop"
+)
+
+// Binary variant.
+//
+// All values are big endian.
+//
+//	magic      [4]byte "BUGA"
+//	width      uint16  in cells
+//	height     uint16  in cells
+//	frames     uint16
+//	loop count int16
+//
+// Followed by each frame:
+//
+//	delay      uint16  in 100ths of a second
+//	cells      [width*height]byte, row by row
+const binaryMagic = "BUGA"
+
+// binaryHeader is the header of the binary variant, after the magic.
+type binaryHeader struct {
+	Width, Height uint16
+	Frames        uint16
+	LoopCount     int16
+}
+
+// isDirective checks if the given line is a text variant directive.
+func isDirective(line []byte) bool {
+	return len(line) > 0 && line[0] == directivePrefix
+}
+
+// isKnownDirective checks if the given line is a well formed directive.
+func isKnownDirective(line []byte) bool {
+	if !isDirective(line) {
+		return false
+	}
+	_, _, err := parseDirective(line)
+	return err == nil
+}
+
+// parseDirective extracts the key/value of the given directive line.
+func parseDirective(line []byte) (string, int, error) {
+	kv := bytes.SplitN(bytes.TrimSpace(line[1:]), []byte{'='}, 2)
+	if len(kv) != 2 {
+		return "", 0, fmt.Errorf("%w: malformed %q", ErrDirective, line)
+	}
+	key := string(kv[0])
+	if key != delayDirective && key != loopDirective {
+		return "", 0, fmt.Errorf("%w: unknown key %q", ErrDirective, key)
+	}
+	val, err := strconv.Atoi(string(kv[1]))
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %s: %s", ErrDirective, key, err)
+	}
+	return key, val, nil
+}
+
+// DecodeAll reads a BUG animation, text or binary, from the given stream.
+// Regular BUG images are returned as single frame animations.
+func DecodeAll(r io.Reader) (*Animation, error) {
+	return NewDecoder(r).DecodeAll()
+}
+
+// DecodeAll reads all the frames from the underlying stream.
+func (d *Decoder) DecodeAll() (*Animation, error) {
+	return d.decode(false)
+}
+
+// decodeBinary decodes the binary variant of the format.
+func (d *Decoder) decodeBinary(buf []byte, firstOnly bool) (*Animation, error) {
+	r := bytes.NewReader(buf[len(binaryMagic):])
+
+	var hdr binaryHeader
+	if err := binary.Read(r, binary.BigEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("invalid BUG animation header: %w", err)
+	}
+	if hdr.Frames == 0 {
+		return nil, errors.New("empty BUG animation")
+	}
+
+	// Make sure the input holds a whole frame before trusting the header's size.
+	frameSize := int(hdr.Width) * int(hdr.Height)
+	if r.Len() < 2+frameSize {
+		return nil, fmt.Errorf("invalid BUG animation frame 0: %w", io.ErrUnexpectedEOF)
+	}
+
+	anim := &Animation{LoopCount: int(hdr.LoopCount)}
+	cells := make([]byte, frameSize)
+	for i := 0; i < int(hdr.Frames); i++ {
+		var delay uint16
+		if err := binary.Read(r, binary.BigEndian, &delay); err != nil {
+			return nil, fmt.Errorf("invalid BUG animation frame %d: %w", i, err)
+		}
+		if _, err := io.ReadFull(r, cells); err != nil {
+			return nil, fmt.Errorf("invalid BUG animation frame %d: %w", i, err)
+		}
+
+		img := newGray(image.Rect(0, 0, int(hdr.Width)*2, int(hdr.Height)*4), d.Threshold)
+		for row := 0; row < int(hdr.Height); row++ {
+			for col := 0; col < int(hdr.Width); col++ {
+				img.setCellValue(col, row, cells[row*int(hdr.Width)+col])
+			}
+		}
+
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, int(delay))
+		if firstOnly {
+			break
+		}
+	}
+	return anim, nil
+}
+
+// EncodeAll writes the frames of the given animation to w
+// using the text variant of the format.
+func EncodeAll(w io.Writer, a *Animation) error {
+	return NewEncoder(w).EncodeAll(a)
+}
+
+// EncodeAllBinary writes the frames of the given animation to w
+// using the binary variant of the format.
+func EncodeAllBinary(w io.Writer, a *Animation) error {
+	return NewEncoder(w).EncodeAllBinary(a)
+}
+
+// validateAnimation makes sure the animation can be encoded.
+func validateAnimation(a *Animation) error {
+	if len(a.Image) == 0 {
+		return errors.New("bug: must provide at least one image")
+	}
+	if len(a.Image) != len(a.Delay) {
+		return errors.New("bug: mismatched image and delay lengths")
+	}
+	return nil
+}
+
+// frameCropOf returns the union of the Crop bounding boxes of the frames of
+// the given animation, relative to the output origin, so that the cropped
+// frames stay aligned. Frames other than BUG images get converted for it.
+func (e *Encoder) frameCropOf(a *Animation) *image.Rectangle {
+	var crop image.Rectangle
+	for _, img := range a.Image {
+		r, fillRow := e.imageCells(img)
+		crop = crop.Union(cropCells(r, fillRow).Sub(r.Min))
+	}
+	return &crop
+}
+
+// EncodeAll writes the frames of the given animation using the text variant.
+// With Crop, all the frames are cropped to the union of their bounding boxes.
+func (e *Encoder) EncodeAll(a *Animation) error {
+	if err := validateAnimation(a); err != nil {
+		return err
+	}
+
+	frameEnc := *e
+	if e.Crop {
+		frameEnc.frameCrop = e.frameCropOf(a)
+	}
+	for i, img := range a.Image {
+		if err := frameEnc.Encode(img); err != nil {
+			return err
+		}
+		if err := e.writeDirective(delayDirective, a.Delay[i]); err != nil {
+			return err
+		}
+	}
+	if a.LoopCount != 0 {
+		if err := e.writeDirective(loopDirective, a.LoopCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDirective writes the given directive line, formatted like the image lines.
+func (e *Encoder) writeDirective(name string, value int) error {
+	eol := "\n"
+	if e.CRLF {
+		eol = "\r\n"
+	}
+	_, err := fmt.Fprintf(e.w, "%s%c%s=%d%s", e.Prefix, directivePrefix, name, value, eol)
+	return err
+}
+
+// EncodeAllBinary writes the frames of the given animation using the binary variant.
+// All the frames must have the same size.
+func (e *Encoder) EncodeAllBinary(a *Animation) error {
+	if err := validateAnimation(a); err != nil {
+		return err
+	}
+	if len(a.Image) > 0xffff {
+		return errors.New("bug: too many frames for the binary format")
+	}
+	if e.Dots == 6 {
+		return errors.New("bug: the binary format doesn't support 6 dots")
+	}
+
+	size := alignCells(a.Image[0]).cellBounds().Size()
+	if size.X > 0xffff || size.Y > 0xffff {
+		return errors.New("bug: image too large for the binary format")
+	}
+	if a.LoopCount < -0x8000 || a.LoopCount > 0x7fff {
+		return fmt.Errorf("bug: loop count %d out of range for the binary format", a.LoopCount)
+	}
+	for i, delay := range a.Delay {
+		if delay < 0 || delay > 0xffff {
+			return fmt.Errorf("bug: frame %d delay %d out of range for the binary format", i, delay)
+		}
+	}
+
+	bw := bufio.NewWriter(e.w)
+	if _, err := bw.WriteString(binaryMagic); err != nil {
+		return err
+	}
+	hdr := binaryHeader{
+		Width:     uint16(size.X),
+		Height:    uint16(size.Y),
+		Frames:    uint16(len(a.Image)),
+		LoopCount: int16(a.LoopCount),
+	}
+	if err := binary.Write(bw, binary.BigEndian, hdr); err != nil {
+		return err
+	}
+
+	for i, frame := range a.Image {
+		img := alignCells(frame)
+		cells := img.cellBounds()
+		if cells.Size() != size {
+			return fmt.Errorf("bug: frame %d size mismatch, expected %v, got %v", i, size, cells.Size())
+		}
+		if err := binary.Write(bw, binary.BigEndian, uint16(a.Delay[i])); err != nil {
+			return err
+		}
+		for row := cells.Min.Y; row < cells.Max.Y; row++ {
+			for col := cells.Min.X; col < cells.Max.X; col++ {
+				if err := bw.WriteByte(img.cellAt(col, row)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Play renders the animation on the given terminal, redrawing each
+// frame in place using ANSI escape sequences.
+// Honors the animation's delays and loop count until the context is done.
+func Play(ctx context.Context, w io.Writer, a *Animation) error {
+	return NewEncoder(w).Play(ctx, a)
+}
+
+// Play renders the animation on the encoder's writer like the package-level
+// Play, each frame being encoded with the encoder's options rather than the
+// defaults: Dots, the conversion of non BUG frames and the output formatting.
+// With Crop, all the frames are cropped to the same box, as in EncodeAll.
+func (e *Encoder) Play(ctx context.Context, a *Animation) error {
+	if err := validateAnimation(a); err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(nil)
+	frameEnc := *e
+	frameEnc.w = buf
+	if e.Crop {
+		frameEnc.frameCrop = e.frameCropOf(a)
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	height := 0 // Number of lines of the previous frame, to move the cursor back.
+	for loop := 0; a.LoopCount <= 0 || loop <= a.LoopCount; loop++ {
+		for i, img := range a.Image {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			buf.Reset()
+			if height > 0 {
+				fmt.Fprintf(buf, "\x1b[%dA\r", height)
+			}
+			start := buf.Len()
+			if err := frameEnc.Encode(img); err != nil {
+				return err
+			}
+			if _, err := e.w.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			height = bytes.Count(buf.Bytes()[start:], []byte{'\n'})
+
+			timer.Reset(time.Duration(a.Delay[i]) * 10 * time.Millisecond)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if a.LoopCount < 0 {
+			break
+		}
+	}
+	return nil
+}
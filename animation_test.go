@@ -0,0 +1,180 @@
+package bug
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+)
+
+// newTestAnimation loads the testdata images as a 2 frames animation.
+func newTestAnimation(t *testing.T) *Animation {
+	t.Helper()
+
+	anim := &Animation{LoopCount: 2}
+	for i, name := range []string{"appenginegopher", "biplane"} {
+		img, err := Decode(mustGetFile(t, "testdata/"+name+".bug"))
+		requireNoError(t, err, "Decode testdata image %q.", name)
+		anim.Image = append(anim.Image, img.(*Gray))
+		anim.Delay = append(anim.Delay, 10*(i+1))
+	}
+	return anim
+}
+
+// Test encoding an animation and decoding it back.
+func TestEncodeDecodeAll(t *testing.T) {
+	encodeDecode := func(t *testing.T, encode func(*bytes.Buffer, *Animation) error) {
+		anim := newTestAnimation(t)
+
+		buf := bytes.NewBuffer(nil)
+		requireNoError(t, encode(buf, anim), "Encode animation.")
+
+		// Decoding a single image should yield the first frame.
+		img, name, err := image.Decode(bytes.NewReader(buf.Bytes()))
+		requireNoError(t, err, "Decode first frame.")
+		assertEqual(t, "bug", name, "Unexpected image type name.")
+		assertEqual(t, mustGetFile(t, "testdata/appenginegopher.bug"), encodeString(t, img), "Unexpected first frame.")
+
+		actual, err := DecodeAll(buf)
+		requireNoError(t, err, "Decode animation.")
+		assertEqual(t, len(anim.Image), len(actual.Image), "Unexpected frame count.")
+		assertEqual(t, anim.Delay, actual.Delay, "Unexpected delays.")
+		assertEqual(t, anim.LoopCount, actual.LoopCount, "Unexpected loop count.")
+		for i, img := range actual.Image {
+			assertEqual(t, encodeString(t, anim.Image[i]), encodeString(t, img), "Unexpected frame %d.", i)
+		}
+	}
+	t.Run("text", func(t *testing.T) {
+		encodeDecode(t, func(buf *bytes.Buffer, a *Animation) error { return EncodeAll(buf, a) })
+	})
+	t.Run("binary", func(t *testing.T) {
+		encodeDecode(t, func(buf *bytes.Buffer, a *Animation) error { return EncodeAllBinary(buf, a) })
+	})
+}
+
+// Make sure regular images decode as single frame animations.
+func TestDecodeAllSingleImage(t *testing.T) {
+	anim, err := DecodeAll(mustGetFile(t, "testdata/biplane.bug"))
+	requireNoError(t, err, "Decode testdata image.")
+	assertEqual(t, 1, len(anim.Image), "Unexpected frame count.")
+	assertEqual(t, mustGetFile(t, "testdata/biplane.bug"), encodeString(t, anim.Image[0]), "Unexpected frame.")
+}
+
+// Test invalid animations.
+func TestEncodeDecodeAllInvalid(t *testing.T) {
+	img := NewGray(image.Rect(0, 0, 4, 4))
+	if err := EncodeAll(bytes.NewBuffer(nil), &Animation{}); err == nil {
+		t.Error("Expected error when encoding an empty animation.")
+	}
+	if err := EncodeAll(bytes.NewBuffer(nil), &Animation{Image: []*Gray{img}}); err == nil {
+		t.Error("Expected error when encoding an animation without delays.")
+	}
+	anim := &Animation{Image: []*Gray{img, NewGray(image.Rect(0, 0, 8, 8))}, Delay: []int{0, 0}}
+	if err := EncodeAllBinary(bytes.NewBuffer(nil), anim); err == nil {
+		t.Error("Expected error when encoding a binary animation with mismatched frames.")
+	}
+	if err := EncodeAllBinary(bytes.NewBuffer(nil), &Animation{Image: []*Gray{img}, Delay: []int{70000}}); err == nil {
+		t.Error("Expected error when encoding a binary animation with an out of range delay.")
+	}
+	if err := EncodeAllBinary(bytes.NewBuffer(nil), &Animation{Image: []*Gray{img}, Delay: []int{0}, LoopCount: 40000}); err == nil {
+		t.Error("Expected error when encoding a binary animation with an out of range loop count.")
+	}
+	if _, err := DecodeAll(strings.NewReader("⠀⠀\n;delay=abc\n")); err == nil {
+		t.Error("Expected error when decoding an invalid delay.")
+	}
+	if _, err := DecodeAll(strings.NewReader("⠀⠀\n;foo=1\n")); err == nil {
+		t.Error("Expected error when decoding an unknown directive.")
+	}
+	if _, err := DecodeAll(strings.NewReader(binaryMagic + "\x00\x01")); err == nil {
+		t.Error("Expected error when decoding a truncated binary animation.")
+	}
+	if _, err := DecodeAll(strings.NewReader(binaryMagic + "\xff\xff\xff\xff\x00\x01\x00\x00\x00\x00")); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected unexpected EOF when decoding a binary animation larger than its input, got %v.", err)
+	}
+}
+
+// Test playing an animation.
+func TestPlay(t *testing.T) {
+	anim := newTestAnimation(t)
+	anim.Delay = []int{0, 0}
+	anim.LoopCount = 1
+
+	buf := bytes.NewBuffer(nil)
+	requireNoError(t, Play(context.Background(), buf, anim), "Play animation.")
+	// 2 loops of 2 frames, all but the first one moving the cursor back.
+	assertEqual(t, 3, strings.Count(buf.String(), "\x1b[63A\r"), "Unexpected cursor moves.")
+
+	// With the encoder's options.
+	buf.Reset()
+	requireNoError(t, NewEncoder(buf).WithDots(6).Play(context.Background(), anim), "Play 6 dots animation.")
+	assertEqual(t, 3, strings.Count(buf.String(), "\x1b[84A\r"), "Unexpected 6 dots cursor moves.")
+
+	// Infinite loop, make sure we honor the context.
+	anim.LoopCount = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assertEqual(t, context.Canceled, Play(ctx, bytes.NewBuffer(nil), anim), "Unexpected error.")
+}
+
+// Cropped frames should keep their alignment.
+func TestEncodeAllCrop(t *testing.T) {
+	anim := &Animation{Delay: []int{0, 0}, LoopCount: -1}
+	for _, pt := range []image.Point{{2, 0}, {5, 4}} {
+		img := NewGray(image.Rect(0, 0, 8, 8))
+		img.Set(pt.X, pt.Y, color.Gray{Y: 0})
+		anim.Image = append(anim.Image, img)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	enc.Crop = true
+	requireNoError(t, enc.EncodeAll(anim), "Encode cropped animation.")
+	actual, err := DecodeAll(buf)
+	requireNoError(t, err, "Decode cropped animation.")
+	assertEqual(t, 2, len(actual.Image), "Unexpected frame count.")
+	assertEqual(t, "⠁⠀\n⠀⠀\n", encodeString(t, actual.Image[0]), "Unexpected first cropped frame.")
+	assertEqual(t, "⠀⠀\n⠀⠈\n", encodeString(t, actual.Image[1]), "Unexpected second cropped frame.")
+
+	buf.Reset()
+	requireNoError(t, enc.Play(context.Background(), anim), "Play cropped animation.")
+	assertEqual(t, "⠁⠀\n⠀⠀\n\x1b[2A\r⠀⠀\n⠀⠈\n", buf.String(), "Unexpected cropped play.")
+}
+
+// Blank frames should still decode once cropped or trimmed.
+func TestEncodeAllBlank(t *testing.T) {
+	anim := &Animation{
+		Image: []*Gray{NewGray(image.Rect(0, 0, 4, 8)), NewGray(image.Rect(0, 0, 4, 8))},
+		Delay: []int{1, 2},
+	}
+	for name, tt := range map[string]struct {
+		setup  func(e *Encoder)
+		expect string
+	}{
+		"crop":       {func(e *Encoder) { e.Crop = true }, "⠀\n"},
+		"trim-right": {func(e *Encoder) { e.TrimRight = true }, "⠀\n⠀\n"},
+	} {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf)
+		tt.setup(enc)
+		requireNoError(t, enc.EncodeAll(anim), "Encode %s blank animation.", name)
+		for _, mode := range []DecodeMode{DecodeDefault, DecodeLenient} {
+			actual, err := NewDecoder(bytes.NewReader(buf.Bytes())).WithMode(mode).DecodeAll()
+			requireNoError(t, err, "Decode %s blank animation.", name)
+			assertEqual(t, anim.Delay, actual.Delay, "Unexpected %s blank animation delays.", name)
+			for i, img := range actual.Image {
+				assertEqual(t, tt.expect, encodeString(t, img), "Unexpected %s blank frame %d.", name, i)
+			}
+		}
+	}
+}
+
+func encodeString(tb testing.TB, img image.Image) string {
+	tb.Helper()
+	buf := bytes.NewBuffer(nil)
+	requireNoError(tb, Encode(buf, img), "Encode image.")
+	return buf.String()
+}
@@ -0,0 +1,62 @@
+package bug
+
+import (
+	"image"
+)
+
+// BannerOptions are the banner rendering parameters, see NewBanner.
+type BannerOptions struct {
+	TextOptions
+	// Width, when positive, wraps the words so the banner, including the
+	// effects, fits within Width dots. See WrapText.
+	Width int
+	// Outline draws the glyphs as 1 dot wide outlines around their dots,
+	// spacing them further so the outlines don't merge.
+	Outline bool
+	// Shadow adds a drop shadow, Shadow dots wide below and to the right of
+	// the glyphs, 1 dot away from them. 0 disables it.
+	Shadow int
+}
+
+// NewBanner returns a new image holding the given text drawn with the
+// effects, sized to its bounds and starting at the origin. See NewText.
+// A nil o uses the zero value options.
+func NewBanner(text string, o *BannerOptions) *Gray {
+	if o == nil {
+		o = &BannerOptions{}
+	}
+	to := o.TextOptions
+
+	// Room for the effects around the glyphs.
+	pad, shadow := 0, 0
+	if o.Outline {
+		pad = 1
+		to.Spacing += 2 * pad
+		to.LineSpacing += 2 * pad
+	}
+	if o.Shadow > 0 {
+		// Shifted past the gap around the glyphs.
+		shadow = o.Shadow + 1
+	}
+	if o.Width > 0 {
+		text = WrapText(text, o.Width-2*pad-shadow, &to)
+	}
+
+	r := TextBounds(image.Point{}, text, &to)
+	r = image.Rect(r.Min.X-pad, r.Min.Y-pad, r.Max.X+pad+shadow, r.Max.Y+pad+shadow)
+	img := NewGray(r.Sub(r.Min))
+	img.DrawText(image.Point{}.Sub(r.Min), text, &to)
+
+	if o.Outline {
+		img = img.Dilate(CrossKernel(1)).Outline()
+	}
+	if shadow > 0 {
+		b := img.Bounds()
+		dst := NewGray(b)
+		dst.Composite(b, img, b.Min.Sub(image.Pt(shadow, shadow)), OpOr)
+		dst.Composite(b, img.Dilate(SquareKernel(1)), b.Min, OpAndNot)
+		dst.Composite(b, img, b.Min, OpOr)
+		img = dst
+	}
+	return img
+}
@@ -0,0 +1,64 @@
+package bug
+
+import (
+	"testing"
+)
+
+func TestNewBanner(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		opts   *BannerOptions
+		expect string
+	}{
+		{"plain", &BannerOptions{TextOptions: TextOptions{Font: Font3x5}}, `
+			.#..#.#.
+			#.#.#.#.
+			#.#.##..
+			#.#.#.#.
+			.#..#.#.
+			........
+		`},
+		// The outlines of the glyphs don't merge.
+		{"outline", &BannerOptions{TextOptions: TextOptions{Font: Font3x5}, Outline: true}, `
+			..#....#.#..
+			.#.#..#.#.#.
+			#...#.#...#.
+			#...#.#..#..
+			#...#.#...#.
+			.#.#..#.#.#.
+			..#....#.#..
+			............
+		`},
+		// Even a 1 dot shadow is visible past the gap.
+		{"shadow", &BannerOptions{TextOptions: TextOptions{Font: Font3x5}, Shadow: 1}, `
+			.#..#.#...
+			#.#.#.#...
+			#.#.##..#.
+			#.#.#.#.#.
+			.#..#.#...
+			........#.
+			...#..#.#.
+			..........
+		`},
+	} {
+		img := NewBanner("OK", tt.opts)
+		assertEqual(t, dotPattern(newPatternImage(tt.expect)), dotPattern(img), "Unexpected %s banner.", tt.name)
+		assertAgree(t, img, "Gray after %s banner.", tt.name)
+	}
+
+	// The wrapped banners fit, effects included.
+	for _, o := range []*BannerOptions{
+		{TextOptions: TextOptions{Font: Font3x5}, Width: 12},
+		{TextOptions: TextOptions{Font: Font3x5}, Width: 12, Outline: true},
+		{TextOptions: TextOptions{Font: Font3x5}, Width: 12, Shadow: 2},
+		{TextOptions: TextOptions{Scale: 2}, Width: 30, Outline: true, Shadow: 1},
+	} {
+		img := NewBanner("Deploy OK", o)
+		if img.Bounds().Dx() > o.Width {
+			t.Fatalf("Banner of %d dots wider than %d with %+v.", img.Bounds().Dx(), o.Width, o)
+		}
+		assertAgree(t, img, "Gray after wrapped banner with %+v.", o)
+	}
+
+	assertEqual(t, "(0,0)-(0,0)", NewBanner("", nil).Bounds(), "Unexpected empty banner bounds.")
+}
@@ -0,0 +1,178 @@
+package bug
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxGlyphSize is the maximum width and height of the glyphs of the parsed
+// fonts, in dots, bounding the memory allocated from untrusted headers.
+const maxGlyphSize = 256
+
+// validGlyphSize returns whether the given glyph size is within 0..maxGlyphSize.
+func validGlyphSize(w, h int) bool {
+	return w >= 0 && h >= 0 && w <= maxGlyphSize && h <= maxGlyphSize
+}
+
+// ParseBDF parses a font in the Glyph Bitmap Distribution Format (BDF), as
+// used by X11. The glyphs are looked up by their encoding, expected to be the
+// Unicode code points (ISO10646 or ISO8859-1 fonts), the unencoded ones being
+// skipped. The advance of the glyphs is their horizontal DWIDTH, without
+// kerning. The glyphs missing from the font fall back to Font5x7.
+func ParseBDF(r io.Reader) (*Font, error) {
+	s := bufio.NewScanner(r)
+	line := 0
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: BDF line %d: %s", ErrInvalidFont, line, fmt.Sprintf(format, args...))
+	}
+	// ints parses the n integers following the keyword.
+	ints := func(fields []string, n int) ([]int, error) {
+		if len(fields) < n+1 {
+			return nil, fail("expected %d values for %s", n, fields[0])
+		}
+		values := make([]int, n)
+		for i := range values {
+			v, err := strconv.Atoi(fields[i+1])
+			if err != nil {
+				return nil, fail("invalid %s value %q", fields[0], fields[i+1])
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+
+	var (
+		f               = newFont(0, 0)
+		bbox            []int // Font bounding box: w, h, xoff, yoff.
+		ascent, descent = -1, -1
+		started, ended  bool
+
+		// Current glyph.
+		g        *Glyph
+		encoding int
+		gbox     []int
+	)
+	for !ended && s.Scan() {
+		line++
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if !started {
+			if fields[0] != "STARTFONT" {
+				return nil, fail("expected STARTFONT, got %q", fields[0])
+			}
+			started = true
+			continue
+		}
+
+		var err error
+		var values []int
+		switch fields[0] {
+		case "FONTBOUNDINGBOX":
+			if bbox, err = ints(fields, 4); err == nil && (bbox[0] <= 0 || bbox[1] <= 0 || !validGlyphSize(bbox[0], bbox[1])) {
+				err = fail("invalid FONTBOUNDINGBOX size %dx%d", bbox[0], bbox[1])
+			}
+		case "FONT_ASCENT":
+			if values, err = ints(fields, 1); err == nil {
+				ascent = values[0]
+			}
+		case "FONT_DESCENT":
+			if values, err = ints(fields, 1); err == nil {
+				descent = values[0]
+			}
+		case "STARTCHAR":
+			if bbox == nil {
+				return nil, fail("missing FONTBOUNDINGBOX")
+			}
+			g, encoding, gbox = &Glyph{Advance: bbox[0]}, -1, bbox
+		case "ENCODING":
+			if g == nil {
+				return nil, fail("ENCODING outside of a glyph")
+			}
+			if values, err = ints(fields, 1); err == nil {
+				encoding = values[0]
+			}
+		case "DWIDTH":
+			if g == nil {
+				return nil, fail("DWIDTH outside of a glyph")
+			}
+			if values, err = ints(fields, 1); err == nil && !validGlyphSize(values[0], 0) {
+				err = fail("invalid DWIDTH %d", values[0])
+			} else if err == nil {
+				g.Advance = values[0]
+			}
+		case "BBX":
+			if g == nil {
+				return nil, fail("BBX outside of a glyph")
+			}
+			if gbox, err = ints(fields, 4); err == nil && !validGlyphSize(gbox[0], gbox[1]) {
+				err = fail("invalid BBX size %dx%d", gbox[0], gbox[1])
+			}
+		case "BITMAP":
+			if g == nil {
+				return nil, fail("BITMAP outside of a glyph")
+			}
+			w, h := gbox[0], gbox[1]
+			g.Mask = image.NewAlpha(image.Rect(gbox[2], -gbox[3]-h, gbox[2]+w, -gbox[3]))
+			for y := g.Mask.Rect.Min.Y; y < g.Mask.Rect.Max.Y; y++ {
+				if !s.Scan() {
+					break
+				}
+				line++
+				row, err := hex.DecodeString(strings.TrimSpace(s.Text()))
+				if err != nil || len(row) < (w+7)/8 {
+					return nil, fail("invalid BITMAP row %q", s.Text())
+				}
+				for x := 0; x < w; x++ {
+					if row[x/8]&(0x80>>uint(x%8)) != 0 {
+						g.Mask.Pix[g.Mask.PixOffset(g.Mask.Rect.Min.X+x, y)] = 0xff
+					}
+				}
+			}
+		case "ENDCHAR":
+			if g == nil {
+				return nil, fail("ENDCHAR outside of a glyph")
+			}
+			if g.Mask == nil {
+				g.Mask = image.NewAlpha(image.Rectangle{})
+			}
+			if encoding >= 0 {
+				f.glyphs[rune(encoding)] = g
+			}
+			g = nil
+		case "ENDFONT":
+			ended = true
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read BDF font: %w", err)
+	}
+	if !ended {
+		return nil, fail("unexpected end of font")
+	}
+	if bbox == nil {
+		return nil, fail("missing FONTBOUNDINGBOX")
+	}
+
+	// Without the properties, use the bounding box.
+	if ascent < 0 {
+		ascent = bbox[1] + bbox[3]
+	}
+	if descent < 0 {
+		descent = -bbox[3]
+	}
+	if ascent+descent <= 0 || !validGlyphSize(ascent, descent) {
+		return nil, fail("invalid font height %d", ascent+descent)
+	}
+	f.Height, f.Ascent, f.Fallback = ascent+descent, ascent, Font5x7
+	return f, nil
+}
@@ -0,0 +1,80 @@
+package bug
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestParseBDF(t *testing.T) {
+	f, err := ParseBDF(mustGetFile(t, "testdata/font.bdf"))
+	requireNoError(t, err, "Parse BDF font.")
+	assertEqual(t, 8, f.Height, "Unexpected BDF height.")
+	assertEqual(t, 6, f.Ascent, "Unexpected BDF ascent.")
+	assertEqual(t, Font5x7, f.Fallback, "Unexpected BDF fallback.")
+
+	// The unencoded glyphs are skipped.
+	assertEqual(t, 4, len(f.glyphs), "Unexpected BDF glyph count.")
+	for _, tt := range []struct {
+		r       rune
+		bounds  image.Rectangle
+		advance int
+	}{
+		{'A', image.Rect(0, -6, 4, 0), 5},
+		{'g', image.Rect(0, -4, 4, 2), 5},
+		{'i', image.Rect(1, -5, 2, 0), 5}, // Without DWIDTH, the font's width.
+		{'€', image.Rect(0, -5, 5, 0), 6},
+	} {
+		g := f.Glyph(tt.r)
+		assertEqual(t, tt.bounds, g.Mask.Rect, "Unexpected %q glyph bounds.", tt.r)
+		assertEqual(t, tt.advance, g.Advance, "Unexpected %q glyph advance.", tt.r)
+	}
+	assertEqual(t, Font5x7.glyphs['B'], f.Glyph('B'), "Unexpected fallback glyph.")
+
+	img := NewText("Agi€", &TextOptions{Font: f})
+	assertEqual(t, dotPattern(newPatternImage(`
+		.##..................
+		#..#.......#.....###.
+		#..#..###.......#....
+		####.#..#..#...####..
+		#..#.#..#..#....#....
+		#..#..###..#.....###.
+		........#............
+		......##.............
+	`)), dotPattern(img), "Unexpected BDF text.")
+	assertAgree(t, img, "Gray after BDF text.")
+}
+
+func TestParseBDFErrors(t *testing.T) {
+	const header = "STARTFONT 2.1\nFONTBOUNDINGBOX 4 6 0 -1\n"
+	for _, tt := range []struct {
+		name, font string
+	}{
+		{"empty", ""},
+		{"not bdf", "STARTFNT 2.1\n"},
+		{"truncated", header + "STARTCHAR A\nENCODING 65\n"},
+		{"truncated bitmap", header + "STARTCHAR A\nENCODING 65\nBBX 4 2 0 0\nBITMAP\nF0\n"},
+		{"invalid row", header + "STARTCHAR A\nENCODING 65\nBBX 4 1 0 0\nBITMAP\nZZ\nENDCHAR\nENDFONT\n"},
+		{"short row", header + "STARTCHAR A\nENCODING 65\nBBX 9 1 0 0\nBITMAP\nFF\nENDCHAR\nENDFONT\n"},
+		{"invalid encoding", header + "STARTCHAR A\nENCODING A\nENDCHAR\nENDFONT\n"},
+		{"invalid bbx", header + "STARTCHAR A\nBBX 4 -1 0 0\nENDCHAR\nENDFONT\n"},
+		{"missing bbx values", header + "STARTCHAR A\nBBX 4 1\nENDCHAR\nENDFONT\n"},
+		{"outside of a glyph", header + "BITMAP\nENDFONT\n"},
+		{"missing bounding box", "STARTFONT 2.1\nENDFONT\n"},
+		{"empty bounding box", "STARTFONT 2.1\nFONTBOUNDINGBOX 4 0 0 0\nENDFONT\n"},
+		{"negative bounding box", "STARTFONT 2.1\nFONTBOUNDINGBOX -4 6 0 0\nENDFONT\n"},
+		{"huge bounding box", "STARTFONT 2.1\nFONTBOUNDINGBOX 4 3000000 0 0\nENDFONT\n"},
+		{"huge bbx", header + "STARTCHAR A\nBBX 3000000 3000000 0 0\nBITMAP\nENDCHAR\nENDFONT\n"},
+		{"huge dwidth", header + "STARTCHAR A\nDWIDTH 3000000 0\nENDCHAR\nENDFONT\n"},
+		{"huge height", "STARTFONT 2.1\nFONTBOUNDINGBOX 4 6 0 -1\nFONT_ASCENT 3000000\nENDFONT\n"},
+	} {
+		if _, err := ParseBDF(strings.NewReader(tt.font)); !errors.Is(err, ErrInvalidFont) {
+			t.Fatalf("Expected an invalid font error for %s, got %v.", tt.name, err)
+		}
+	}
+
+	// The errors hold the line.
+	_, err := ParseBDF(strings.NewReader(header + "STARTCHAR A\nDWIDTH x\n"))
+	assertEqual(t, `invalid font: BDF line 4: invalid DWIDTH value "x"`, err.Error(), "Unexpected error.")
+}
@@ -0,0 +1,245 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+)
+
+// cellImage is implemented by the BUG image types, giving direct access to their cells.
+type cellImage interface {
+	image.Image
+
+	// cellBounds returns the image's bounds, in cells.
+	cellBounds() image.Rectangle
+	// cellAt returns the value of the given cell, limited to the pixels
+	// within the image's bounds.
+	cellAt(col, row int) uint8
+}
+
+// Bitmap is a bit-packed Black and White BUG image.
+// Unlike Gray, it only stores the braille cells, one byte per 2x4 pixels,
+// without any grayscale data.
+type Bitmap struct {
+	// Pix holds the braille cells. The cell at col, row starts at
+	// Pix[(row-Cells.Min.Y)*Stride + (col-Cells.Min.X)], Cells being
+	// the result of the Cells method.
+	Pix []uint8
+	// Stride is the Pix stride (in cells) between two vertically adjacent cells.
+	Stride int
+	// Rect is the image's bounds, in "real" pixels.
+	Rect image.Rectangle
+
+	// Threshold to toogle braille point based on gray scale.
+	Threshold Threshold
+
+	// Classifier, when set, is used instead of Threshold to classify the colors.
+	Classifier Classifier
+}
+
+// NewBitmap creates a new bit-packed BUG image.
+// The rectangle is expected to be in "real" pixels.
+func NewBitmap(r image.Rectangle) *Bitmap {
+	cells := cellRect(r)
+	return &Bitmap{
+		Pix:       make([]uint8, cells.Dx()*cells.Dy()),
+		Stride:    cells.Dx(),
+		Rect:      r,
+		Threshold: DefaultThreshold,
+	}
+}
+
+// Cells returns the image's bounds, in cells.
+func (p *Bitmap) Cells() image.Rectangle {
+	return cellRect(p.Rect)
+}
+
+// cellBounds implements the cellImage interface.
+func (p *Bitmap) cellBounds() image.Rectangle {
+	return p.Cells()
+}
+
+// Bounds implements the image.Image interface.
+func (p *Bitmap) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// ColorModel implements the image.Image interface. It defines the
+// grayscale threshold when to set the braille character point,
+// or the Classifier when set.
+func (p *Bitmap) ColorModel() color.Model {
+	return colorModel(p.Threshold, p.Classifier)
+}
+
+// cellOffset returns the index in Pix of the cell holding the "real" pixel x,y.
+func (p *Bitmap) cellOffset(x, y int) int {
+	cells := p.Cells()
+	return (floorDiv(y, 4)-cells.Min.Y)*p.Stride + (floorDiv(x, 2) - cells.Min.X)
+}
+
+// At implements the image.Image interface.
+// Returns the canonical color of the braille point for the given pixel.
+func (p *Bitmap) At(x, y int) color.Color {
+	return p.dotAt(x, y)
+}
+
+// RGBA64At implements the image.RGBA64Image interface.
+func (p *Bitmap) RGBA64At(x, y int) color.RGBA64 {
+	r, g, b, a := p.dotAt(x, y).RGBA()
+	return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+}
+
+// dotAt returns the canonical color of the braille point for the given pixel.
+func (p *Bitmap) dotAt(x, y int) color.Gray {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.Gray{}
+	}
+	return p.Threshold.dotColor(p.Pix[p.cellOffset(x, y)]&unicodeOffset(x, y) != 0)
+}
+
+// Set implements the draw.Image interface.
+func (p *Bitmap) Set(x, y int, c color.Color) {
+	if p.Classifier != nil {
+		p.SetDot(x, y, p.Classifier.IsDot(c))
+		return
+	}
+	p.SetDot(x, y, p.Threshold.isDot(c))
+}
+
+// SetRGBA64 implements the draw.RGBA64Image interface.
+func (p *Bitmap) SetRGBA64(x, y int, c color.RGBA64) {
+	p.Set(x, y, c)
+}
+
+// SetDot sets or removes the braille point of the given "real" pixel x,y.
+func (p *Bitmap) SetDot(x, y int, dot bool) {
+	// Discard pixels outside the image.
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	if dot {
+		p.Pix[p.cellOffset(x, y)] |= unicodeOffset(x, y)
+	} else {
+		p.Pix[p.cellOffset(x, y)] &^= unicodeOffset(x, y)
+	}
+}
+
+// setCellBits implements the cellDrawer interface.
+func (p *Bitmap) setCellBits(col, row int, mask, bits uint8) {
+	cells := p.Cells()
+	cell := &p.Pix[(row-cells.Min.Y)*p.Stride+(col-cells.Min.X)]
+	*cell = *cell&^mask | bits&mask
+}
+
+// setLumaRow implements the cellDrawer interface.
+// Same as calling Set for each pixel with a color.Gray.
+func (p *Bitmap) setLumaRow(x, y int, luma []uint8) {
+	if p.Classifier != nil {
+		for i, l := range luma {
+			p.SetDot(x+i, y, p.Classifier.IsDot(color.Gray{Y: l}))
+		}
+		return
+	}
+	for i, l := range luma {
+		p.SetDot(x+i, y, p.Threshold.isDotY(l))
+	}
+}
+
+// cellMask returns the bits of the given cell corresponding to pixels
+// within the image's bounds.
+func (p *Bitmap) cellMask(col, row int) uint8 {
+	return rectCellMask(p.Rect, col, row)
+}
+
+// cellAt implements the cellImage interface.
+func (p *Bitmap) cellAt(col, row int) uint8 {
+	cells := p.Cells()
+	return p.Pix[(row-cells.Min.Y)*p.Stride+(col-cells.Min.X)] & p.cellMask(col, row)
+}
+
+// BrailleAt returns the Braille Unicode for the given cell.
+func (p *Bitmap) BrailleAt(col, row int) rune {
+	// Discard pixels outside the image.
+	if !(image.Point{col, row}.In(p.Cells())) {
+		return brailleCharOffset
+	}
+	return rune(p.cellAt(col, row)) + brailleCharOffset
+}
+
+// Clear all pixels.
+func (p *Bitmap) Clear() {
+	cells := p.Cells()
+	for row := cells.Min.Y; row < cells.Max.Y; row++ {
+		line := p.Pix[(row-cells.Min.Y)*p.Stride:]
+		for col := cells.Min.X; col < cells.Max.X; col++ {
+			// Only clear the pixels within the bounds, in case the
+			// image is a view sharing its edge cells with its parent.
+			line[col-cells.Min.X] &^= p.cellMask(col, row)
+		}
+	}
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value, a *Bitmap, shares cells with the original image.
+func (p *Bitmap) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed to be inside
+	// either r1 or r2 if the intersection is empty. Without explicitly checking for
+	// this, the Pix[i:] expression below can panic.
+	if r.Empty() {
+		return &Bitmap{Threshold: p.Threshold, Classifier: p.Classifier}
+	}
+	cells, parent := cellRect(r), p.Cells()
+	i := (cells.Min.Y-parent.Min.Y)*p.Stride + (cells.Min.X - parent.Min.X)
+	return &Bitmap{
+		Pix:        p.Pix[i:],
+		Stride:     p.Stride,
+		Rect:       r,
+		Threshold:  p.Threshold,
+		Classifier: p.Classifier,
+	}
+}
+
+// Gray converts the bitmap to a Gray image, with canonical grayscale values.
+func (p *Bitmap) Gray() *Gray {
+	img := newGray(p.Rect, p.Threshold)
+	img.Classifier = p.Classifier
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for x := p.Rect.Min.X; x < p.Rect.Max.X; x++ {
+			if p.Pix[p.cellOffset(x, y)]&unicodeOffset(x, y) != 0 {
+				img.Gray.SetGray(x, y, img.Threshold.dotColor(true))
+				img.setDot(x, y, true)
+			}
+		}
+	}
+	return img
+}
+
+// Bitmap converts the image to a bit-packed one, dropping the grayscale data.
+func (p *Gray) Bitmap() *Bitmap {
+	img := NewBitmap(p.Gray.Rect)
+	img.Threshold, img.Classifier = p.Threshold, p.Classifier
+	cells := img.Cells()
+	for row := cells.Min.Y; row < cells.Max.Y; row++ {
+		for col := cells.Min.X; col < cells.Max.X; col++ {
+			img.Pix[(row-cells.Min.Y)*img.Stride+(col-cells.Min.X)] = p.cellAt(col, row)
+		}
+	}
+	return img
+}
+
+// alignCells returns the image with its origin at 0,0 so the cells map
+// exactly to its bounds, as expected by the encoders. Returns img itself
+// when its origin already is on a cell boundary.
+func alignCells(img cellImage) cellImage {
+	b := img.Bounds()
+	if b.Min.X%2 == 0 && b.Min.Y%4 == 0 {
+		return img
+	}
+	aligned := NewBitmap(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			aligned.SetDot(x-b.Min.X, y-b.Min.Y, img.cellAt(floorDiv(x, 2), floorDiv(y, 4))&unicodeOffset(x, y) != 0)
+		}
+	}
+	return aligned
+}
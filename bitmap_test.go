@@ -0,0 +1,103 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// Make sure *Bitmap implements the draw.Image interface.
+var _ draw.Image = (*Bitmap)(nil)
+
+// Test converting between Gray and Bitmap.
+func TestBitmapConvert(t *testing.T) {
+	convert := func(t *testing.T, name string) {
+		expect := mustGetFile(t, "testdata/"+name+".bug").String()
+		img, _, err := image.Decode(mustGetFile(t, "testdata/"+name+".png"))
+		requireNoError(t, err, "Decode testdata image %q.", name)
+
+		// Drawing directly on a bitmap.
+		bitmap := NewBitmap(img.Bounds())
+		draw.Draw(bitmap, bitmap.Bounds(), img, img.Bounds().Min, draw.Src)
+		assertEqual(t, expect, encodeString(t, bitmap), "Unexpected drawn bitmap.")
+
+		// Gray to Bitmap.
+		gray := Convert(img, DefaultThreshold)
+		assertEqual(t, expect, encodeString(t, gray.Bitmap()), "Unexpected converted bitmap.")
+
+		// Bitmap to Gray.
+		back := bitmap.Gray()
+		assertAgree(t, back, "Gray from bitmap %q.", name)
+		assertEqual(t, expect, encodeString(t, back), "Unexpected converted gray.")
+		assertEqual(t, expect, encodeString(t, Convert(bitmap, DefaultThreshold)), "Unexpected converted gray.")
+
+		// Pixels should match.
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if bitmap.At(x, y) != gray.At(x, y) {
+					t.Fatalf("Unexpected pixel at %d,%d: %v != %v.", x, y, bitmap.At(x, y), gray.At(x, y))
+				}
+			}
+		}
+	}
+	t.Run("appenginegopher", func(t *testing.T) { convert(t, "appenginegopher") })
+	t.Run("biplane", func(t *testing.T) { convert(t, "biplane") })
+}
+
+// Test bitmaps with bounds not starting at 0,0 and their views.
+func TestBitmapSubImage(t *testing.T) {
+	r := image.Rect(-3, -5, 17, 14)
+	gray, bitmap := NewGray(r), NewBitmap(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.Gray{Y: uint8((x*7 + y*13) * 5)}
+			gray.Set(x, y, c)
+			bitmap.Set(x, y, c)
+		}
+	}
+	assertEqual(t, encodeString(t, gray), encodeString(t, bitmap), "Unexpected bitmap.")
+
+	sr := image.Rect(1, -1, 9, 10)
+	sub := bitmap.SubImage(sr).(*Bitmap)
+	assertEqual(t, encodeString(t, gray.SubImage(sr)), encodeString(t, sub), "Unexpected bitmap view.")
+
+	// Clearing the view should only affect its pixels.
+	sub.Clear()
+	gray.SubImage(sr).(*Gray).Clear()
+	assertEqual(t, encodeString(t, gray), encodeString(t, bitmap), "Unexpected bitmap after clearing its view.")
+	assertEqual(t, string(brailleCharOffset), string(sub.BrailleAt(1, 1)), "Unexpected cleared cell.")
+}
+
+// benchmarkSet sets all the pixels of the given image.
+func benchmarkSet(b *testing.B, img draw.Image) {
+	bounds := img.Bounds()
+	colors := [2]color.Color{color.Gray{Y: 0x20}, color.Gray{Y: 0xe0}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				img.Set(x, y, colors[(x^y)&1])
+			}
+		}
+	}
+}
+
+func BenchmarkGraySet(b *testing.B)   { benchmarkSet(b, NewGray(image.Rect(0, 0, 512, 512))) }
+func BenchmarkBitmapSet(b *testing.B) { benchmarkSet(b, NewBitmap(image.Rect(0, 0, 512, 512))) }
+
+// Memory: see the B/op.
+func BenchmarkNewGray(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = NewGray(image.Rect(0, 0, 1024, 1024))
+	}
+}
+
+func BenchmarkNewBitmap(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = NewBitmap(image.Rect(0, 0, 1024, 1024))
+	}
+}
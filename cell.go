@@ -0,0 +1,144 @@
+package bug
+
+import (
+	"image"
+)
+
+// Cell-level API. The cells are addressed by column and row, i.e. the
+// "real" pixel x,y belongs to the cell x/2, y/4 (rounding down).
+// Cells at the edge of an image not aligned on the cell grid only
+// hold the dots of the pixels within the image's bounds.
+
+// CellAt returns the raw value of the given cell, i.e. its braille dots
+// as defined by the Unicode braille patterns (U+2800 + value).
+// Returns 0 outside the image.
+func (p *Gray) CellAt(col, row int) uint8 {
+	return cellValue(p, col, row)
+}
+
+// SetCell sets the raw value of the given cell, updating the "real"
+// pixels to the canonical colors of their dots.
+func (p *Gray) SetCell(col, row int, v uint8) {
+	setCell(p, col, row, v)
+}
+
+// SetCellRune sets the given cell from a braille rune.
+// Returns ErrInvalidRune if r is not within U+2800 - U+28FF.
+func (p *Gray) SetCellRune(col, row int, r rune) error {
+	return setCellRune(p, col, row, r)
+}
+
+// RangeCells calls f for each cell of the image, row by row.
+// If f returns false, RangeCells stops the iteration.
+func (p *Gray) RangeCells(f func(col, row int, v uint8) bool) {
+	rangeCells(p, f)
+}
+
+// FillCells sets all the cells within r, in cells, to the given value.
+func (p *Gray) FillCells(r image.Rectangle, v uint8) {
+	fillCells(p, r, v)
+}
+
+// ClearCells removes all the dots of the cells within r, in cells.
+func (p *Gray) ClearCells(r image.Rectangle) {
+	fillCells(p, r, 0)
+}
+
+// InvertCells toggles all the dots of the cells within r, in cells.
+func (p *Gray) InvertCells(r image.Rectangle) {
+	invertCells(p, r)
+}
+
+// CellAt returns the raw value of the given cell. See Gray.CellAt.
+func (p *Bitmap) CellAt(col, row int) uint8 {
+	return cellValue(p, col, row)
+}
+
+// SetCell sets the raw value of the given cell.
+func (p *Bitmap) SetCell(col, row int, v uint8) {
+	setCell(p, col, row, v)
+}
+
+// SetCellRune sets the given cell from a braille rune.
+// Returns ErrInvalidRune if r is not within U+2800 - U+28FF.
+func (p *Bitmap) SetCellRune(col, row int, r rune) error {
+	return setCellRune(p, col, row, r)
+}
+
+// RangeCells calls f for each cell of the image, row by row.
+// If f returns false, RangeCells stops the iteration.
+func (p *Bitmap) RangeCells(f func(col, row int, v uint8) bool) {
+	rangeCells(p, f)
+}
+
+// FillCells sets all the cells within r, in cells, to the given value.
+func (p *Bitmap) FillCells(r image.Rectangle, v uint8) {
+	fillCells(p, r, v)
+}
+
+// ClearCells removes all the dots of the cells within r, in cells.
+func (p *Bitmap) ClearCells(r image.Rectangle) {
+	fillCells(p, r, 0)
+}
+
+// InvertCells toggles all the dots of the cells within r, in cells.
+func (p *Bitmap) InvertCells(r image.Rectangle) {
+	invertCells(p, r)
+}
+
+// cellValue returns the value of the given cell, 0 outside the image.
+func cellValue(img cellImage, col, row int) uint8 {
+	if !(image.Point{col, row}.In(img.cellBounds())) {
+		return 0
+	}
+	return img.cellAt(col, row)
+}
+
+// setCell sets the value of the given cell, limited to the pixels within the image.
+func setCell(img cellDrawer, col, row int, v uint8) {
+	if !(image.Point{col, row}.In(img.cellBounds())) {
+		return
+	}
+	img.setCellBits(col, row, rectCellMask(img.Bounds(), col, row), v)
+}
+
+// setCellRune sets the given cell from a braille rune.
+func setCellRune(img cellDrawer, col, row int, r rune) error {
+	if !isBraille(r) {
+		return ErrInvalidRune
+	}
+	setCell(img, col, row, uint8(r-brailleCharOffset))
+	return nil
+}
+
+// rangeCells calls f for each cell of img until it returns false.
+func rangeCells(img cellImage, f func(col, row int, v uint8) bool) {
+	r := img.cellBounds()
+	for row := r.Min.Y; row < r.Max.Y; row++ {
+		for col := r.Min.X; col < r.Max.X; col++ {
+			if !f(col, row, img.cellAt(col, row)) {
+				return
+			}
+		}
+	}
+}
+
+// fillCells sets the cells within r to v.
+func fillCells(img cellDrawer, r image.Rectangle, v uint8) {
+	r = r.Intersect(img.cellBounds())
+	for row := r.Min.Y; row < r.Max.Y; row++ {
+		for col := r.Min.X; col < r.Max.X; col++ {
+			img.setCellBits(col, row, rectCellMask(img.Bounds(), col, row), v)
+		}
+	}
+}
+
+// invertCells toggles the dots of the cells within r.
+func invertCells(img cellDrawer, r image.Rectangle) {
+	r = r.Intersect(img.cellBounds())
+	for row := r.Min.Y; row < r.Max.Y; row++ {
+		for col := r.Min.X; col < r.Max.X; col++ {
+			img.setCellBits(col, row, rectCellMask(img.Bounds(), col, row), ^img.cellAt(col, row))
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package bug
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+// cellEditor is the cell-level API of the BUG image types.
+type cellEditor interface {
+	image.Image
+	CellAt(col, row int) uint8
+	SetCell(col, row int, v uint8)
+	SetCellRune(col, row int, r rune) error
+	RangeCells(f func(col, row int, v uint8) bool)
+	FillCells(r image.Rectangle, v uint8)
+	ClearCells(r image.Rectangle)
+	InvertCells(r image.Rectangle)
+}
+
+// Test the cell-level API on both image types.
+func TestCells(t *testing.T) {
+	images := map[string]func(r image.Rectangle) cellEditor{
+		"gray":   func(r image.Rectangle) cellEditor { return NewGray(r) },
+		"bitmap": func(r image.Rectangle) cellEditor { return NewBitmap(r) },
+	}
+	for name, newImage := range images {
+		img := newImage(image.Rect(0, 0, 8, 12))
+
+		img.SetCell(1, 1, 0x41)
+		assertEqual(t, 0x41, img.CellAt(1, 1), "Unexpected %s cell.", name)
+		assertEqual(t, 0, img.CellAt(10, 1), "Unexpected %s cell outside the image.", name)
+		requireNoError(t, img.SetCellRune(2, 0, '⣿'), "SetCellRune.")
+		if err := img.SetCellRune(3, 0, 'A'); !errors.Is(err, ErrInvalidRune) {
+			t.Fatalf("Unexpected error for a non braille rune: %v.", err)
+		}
+		assertEqual(t, "⠀⠀⣿⠀\n⠀⡁⠀⠀\n⠀⠀⠀⠀\n", encodeString(t, img), "Unexpected %s after SetCell.", name)
+
+		img.FillCells(image.Rect(-1, 1, 2, 10), 0x09)
+		assertEqual(t, "⠀⠀⣿⠀\n⠉⠉⠀⠀\n⠉⠉⠀⠀\n", encodeString(t, img), "Unexpected %s after FillCells.", name)
+		img.InvertCells(image.Rect(1, 0, 3, 2))
+		assertEqual(t, "⠀⣿⠀⠀\n⠉⣶⣿⠀\n⠉⠉⠀⠀\n", encodeString(t, img), "Unexpected %s after InvertCells.", name)
+		img.ClearCells(image.Rect(0, 1, 4, 2))
+		assertEqual(t, "⠀⣿⠀⠀\n⠀⠀⠀⠀\n⠉⠉⠀⠀\n", encodeString(t, img), "Unexpected %s after ClearCells.", name)
+
+		var cells []string
+		img.RangeCells(func(col, row int, v uint8) bool {
+			if v != 0 {
+				cells = append(cells, string(brailleCharOffset+rune(v)))
+			}
+			return row < 2
+		})
+		assertEqual(t, "⣿ ⠉", strings.Join(cells, " "), "Unexpected %s iterated cells.", name)
+
+		// Pixels should match.
+		assertEqual(t, true, img.At(2, 0) == DefaultThreshold.dotColor(true), "Unexpected %s pixel.", name)
+		assertEqual(t, true, img.At(0, 0) == DefaultThreshold.dotColor(false), "Unexpected %s pixel.", name)
+		if g, ok := img.(*Gray); ok {
+			assertAgree(t, g, "Gray after cell updates.")
+		}
+	}
+}
+
+// Test the cell-level API on views not aligned on the cell grid.
+func TestCellsSubImage(t *testing.T) {
+	img := NewGray(image.Rect(0, 0, 8, 8))
+	sub := img.SubImage(image.Rect(1, 2, 5, 8)).(*Gray)
+
+	// Only the pixels within the view are affected.
+	sub.FillCells(sub.Rect, 0xff)
+	assertEqual(t, "⢠⣤⡄⠀\n⢸⣿⡇⠀\n", encodeString(t, img), "Unexpected parent after filling the view.")
+	assertEqual(t, 0xa0, sub.CellAt(0, 0), "Unexpected view edge cell.")
+	sub.InvertCells(sub.Rect)
+	assertEqual(t, "⠀⠀⠀⠀\n⠀⠀⠀⠀\n", encodeString(t, img), "Unexpected parent after inverting the view.")
+	assertAgree(t, img, "Gray after cell updates on a view.")
+}
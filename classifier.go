@@ -0,0 +1,212 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Classifier decides which colors set a braille dot.
+// Set as the Classifier of Gray, Bitmap, Encoder or Options, it is used
+// instead of the Threshold to classify the colors.
+// Threshold implements it, as well as the built-in classifiers below.
+type Classifier interface {
+	IsDot(c color.Color) bool
+}
+
+// IsDot implements the Classifier interface.
+func (cm Threshold) IsDot(c color.Color) bool {
+	return cm.isDot(c)
+}
+
+// Luminance classifies the colors by their luminance with custom channel
+// weights, e.g. {R: 1, Threshold: 100} for the red channel only. Like
+// Threshold, the dot is set below it or above its opposite when negative,
+// without special cases.
+type Luminance struct {
+	R, G, B   float64
+	Threshold Threshold
+}
+
+// IsDot implements the Classifier interface.
+func (l Luminance) IsDot(c color.Color) bool {
+	sum := l.R + l.G + l.B
+	if sum == 0 {
+		return false
+	}
+	r, g, b, _ := c.RGBA()
+	y := (l.R*float64(r) + l.G*float64(g) + l.B*float64(b)) / sum / 0x101
+	return l.Threshold.isDotY(uint8(math.Round(y)))
+}
+
+// Channel is a color channel.
+type Channel int
+
+// Color channels.
+const (
+	ChannelRed Channel = iota
+	ChannelGreen
+	ChannelBlue
+	ChannelAlpha
+)
+
+// ChannelRange sets the dot when the given channel, not alpha premultiplied,
+// is within Min and Max included, e.g. {ChannelAlpha, 0x80, 0xff} for the
+// opaque pixels.
+type ChannelRange struct {
+	Channel  Channel
+	Min, Max uint8
+}
+
+// IsDot implements the Classifier interface.
+func (cr ChannelRange) IsDot(c color.Color) bool {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	v := n.A
+	switch cr.Channel {
+	case ChannelRed:
+		v = n.R
+	case ChannelGreen:
+		v = n.G
+	case ChannelBlue:
+		v = n.B
+	}
+	return v >= cr.Min && v <= cr.Max
+}
+
+// HSVRange sets the dot when the color's hue, saturation and value are within
+// the given ranges, e.g. to key on a single color of a chart.
+// Hues are in degrees, wrapping around when HueMin is greater than HueMax,
+// e.g. 330 to 30 for the reds. Saturations and values are from 0 to 1,
+// a zero SatMax or ValMax meaning 1. Transparent pixels never set the dot.
+type HSVRange struct {
+	HueMin, HueMax float64
+	SatMin, SatMax float64
+	ValMin, ValMax float64
+}
+
+// IsDot implements the Classifier interface.
+func (hr HSVRange) IsDot(c color.Color) bool {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 0 {
+		return false
+	}
+	h, s, v := hsv(n)
+	satMax, valMax := hr.SatMax, hr.ValMax
+	if satMax == 0 {
+		satMax = 1
+	}
+	if valMax == 0 {
+		valMax = 1
+	}
+	if s < hr.SatMin || s > satMax || v < hr.ValMin || v > valMax {
+		return false
+	}
+	if hr.HueMin <= hr.HueMax {
+		return h >= hr.HueMin && h <= hr.HueMax
+	}
+	return h >= hr.HueMin || h <= hr.HueMax
+}
+
+// hsv returns the hue, in degrees, saturation and value of the given color.
+func hsv(c color.NRGBA) (h, s, v float64) {
+	r, g, b := float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	v, delta := max, max-min
+	if max > 0 {
+		s = delta / max
+	}
+	switch {
+	case delta == 0:
+		h = 0
+	case max == r:
+		h = 60 * math.Mod((g-b)/delta, 6)
+	case max == g:
+		h = 60 * ((b-r)/delta + 2)
+	default:
+		h = 60 * ((r-g)/delta + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
+
+// ColorDistance sets the dot when the color is within Max of Color, using the
+// euclidean distance of their red, green and blue channels, from 0 to 255 and
+// not alpha premultiplied. Transparent pixels never set the dot.
+type ColorDistance struct {
+	Color color.Color
+	Max   float64
+}
+
+// IsDot implements the Classifier interface.
+func (cd ColorDistance) IsDot(c color.Color) bool {
+	n, ref := color.NRGBAModel.Convert(c).(color.NRGBA), color.NRGBAModel.Convert(cd.Color).(color.NRGBA)
+	if n.A == 0 {
+		return false
+	}
+	dr, dg, db := float64(n.R)-float64(ref.R), float64(n.G)-float64(ref.G), float64(n.B)-float64(ref.B)
+	return math.Sqrt(dr*dr+dg*dg+db*db) <= cd.Max
+}
+
+// Not returns a classifier setting the dots c doesn't, e.g. to remove a
+// chroma key background.
+func Not(c Classifier) Classifier {
+	return not{c}
+}
+
+// not implements the Not classifier.
+type not struct {
+	Classifier
+}
+
+// IsDot implements the Classifier interface.
+func (n not) IsDot(c color.Color) bool {
+	return !n.Classifier.IsDot(c)
+}
+
+// classifierModel is the color model of the BUG images using a Classifier.
+// Like Threshold, it converts the colors to color.Opaque when they set
+// a dot, color.Transparent otherwise.
+type classifierModel struct {
+	Classifier
+}
+
+// Convert implements the color.Model interface.
+func (m classifierModel) Convert(c color.Color) color.Color {
+	if m.IsDot(c) {
+		return color.Opaque
+	}
+	return color.Transparent
+}
+
+// colorModel returns the color model of a BUG image with the given
+// threshold and classifier.
+func colorModel(t Threshold, c Classifier) color.Model {
+	if c != nil {
+		return classifierModel{c}
+	}
+	return t
+}
+
+// thresholdOf returns the threshold of the given BUG image, DefaultThreshold if none.
+func thresholdOf(img image.Image) Threshold {
+	switch img := img.(type) {
+	case *Gray:
+		return img.Threshold
+	case *Bitmap:
+		return img.Threshold
+	}
+	return DefaultThreshold
+}
+
+// classifierOf returns the classifier of the given BUG image, nil if none.
+func classifierOf(img image.Image) Classifier {
+	switch img := img.(type) {
+	case *Gray:
+		return img.Classifier
+	case *Bitmap:
+		return img.Classifier
+	}
+	return nil
+}
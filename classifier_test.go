@@ -0,0 +1,106 @@
+package bug
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestClassifiers(t *testing.T) {
+	red := color.NRGBA{R: 0xe0, G: 0x20, B: 0x30, A: 0xff}
+	darkRed := color.NRGBA{R: 0x60, G: 0x08, B: 0x10, A: 0xff}
+	green := color.NRGBA{R: 0x20, G: 0xc0, B: 0x20, A: 0xff}
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	halfRed := color.NRGBA{R: 0xe0, G: 0x20, B: 0x30, A: 0x80}
+
+	for _, tt := range []struct {
+		name       string
+		classifier Classifier
+		expect     map[color.Color]bool
+	}{
+		{"threshold", DefaultThreshold, map[color.Color]bool{red: true, green: false, white: false, color.Opaque: true}},
+		{"luminance", Luminance{G: 1, Threshold: 100}, map[color.Color]bool{red: true, green: false, white: false, color.Opaque: false}},
+		{"luminance inverse", Luminance{R: 1, Threshold: -100}, map[color.Color]bool{red: true, green: false, white: true}},
+		{"luminance no weights", Luminance{Threshold: 100}, map[color.Color]bool{red: false, color.Black: false}},
+		{"red channel", ChannelRange{ChannelRed, 0x80, 0xff}, map[color.Color]bool{red: true, darkRed: false, green: false, white: true, halfRed: true}},
+		{"alpha", ChannelRange{ChannelAlpha, 0x80, 0xff}, map[color.Color]bool{red: true, halfRed: true, color.Transparent: false}},
+		{"hsv", HSVRange{HueMin: 330, HueMax: 30, SatMin: 0.5}, map[color.Color]bool{red: true, darkRed: true, green: false, white: false, color.Transparent: false}},
+		{"hsv value", HSVRange{HueMin: 330, HueMax: 30, SatMin: 0.5, ValMin: 0.5}, map[color.Color]bool{red: true, darkRed: false}},
+		{"hsv green", HSVRange{HueMin: 90, HueMax: 150}, map[color.Color]bool{red: false, green: true}},
+		{"distance", ColorDistance{Color: red, Max: 40}, map[color.Color]bool{red: true, halfRed: true, darkRed: false, green: false}},
+		{"not", Not(HSVRange{HueMin: 90, HueMax: 150, SatMin: 0.5}), map[color.Color]bool{red: true, green: false, white: true}},
+	} {
+		for c, expect := range tt.expect {
+			assertEqual(t, expect, tt.classifier.IsDot(c), "Unexpected %s classification of %v.", tt.name, c)
+		}
+	}
+}
+
+// newChartImage returns a white chart with a black axis and a red line.
+func newChartImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+	for i := 0; i < 8; i++ {
+		img.Set(0, i, color.Black)
+		img.Set(i, 7, color.Black)
+	}
+	for i := 0; i < 8; i++ {
+		img.Set(i, 7-i, color.RGBA{R: 0xff, A: 0xff})
+	}
+	return img
+}
+
+// Test extracting the red line of a chart.
+func TestConvertClassifier(t *testing.T) {
+	src := newChartImage()
+	key := HSVRange{HueMin: 330, HueMax: 30, SatMin: 0.5}
+	expect := `
+		.......#
+		......#.
+		.....#..
+		....#...
+		...#....
+		..#.....
+		.#......
+		#.......
+	`
+	img := ConvertWithOptions(src, &Options{Threshold: DefaultThreshold, Classifier: key})
+	assertEqual(t, dotPattern(newPatternImage(expect)), dotPattern(img), "Unexpected classified conversion.")
+	assertAgree(t, img, "Gray after classified conversion.")
+	red := color.RGBA{R: 0xff, A: 0xff}
+	assertEqual(t, color.Opaque, img.ColorModel().Convert(red), "The color model should use the classifier.")
+	assertEqual(t, color.Transparent, img.ColorModel().Convert(color.Black), "The color model should use the classifier.")
+	assertEqual(t, color.Opaque, img.Bitmap().ColorModel().Convert(red), "The bitmap color model should use the classifier.")
+	img.Rethreshold()
+	assertEqual(t, dotPattern(newPatternImage(expect)), dotPattern(img), "Unexpected classified conversion after Rethreshold.")
+	assertEqual(t, nil, img.Classifier, "Rethreshold should drop the classifier.")
+	assertEqual(t, color.Model(DefaultThreshold), img.ColorModel(), "Unexpected color model after Rethreshold.")
+
+	// Classifying a BUG image only has its grayscale.
+	bugImg := Convert(src, DefaultThreshold)
+	assertEqual(t, "⠀⠀⠀⠀\n⠀⠀⠀⠀\n", encodeString(t, ConvertWithOptions(bugImg, &Options{Classifier: key})), "Unexpected classified BUG image.")
+	assertEqual(t, "⡇⠀⡠⠊\n⣧⣊⣀⣀\n", encodeString(t, ConvertWithOptions(bugImg, &Options{Classifier: Luminance{R: 1, Threshold: 100}})), "Unexpected classified BUG image.")
+
+	// Same with a BUG destination or the encoder.
+	gray, bitmap := NewGray(src.Rect), NewBitmap(src.Rect)
+	gray.Classifier, bitmap.Classifier = key, key
+	Draw(gray, gray.Bounds(), src, image.Point{})
+	Draw(bitmap, bitmap.Bounds(), src, image.Point{})
+	assertEqual(t, encodeString(t, img), encodeString(t, gray), "Unexpected classified Draw to Gray.")
+	assertEqual(t, encodeString(t, img), encodeString(t, bitmap), "Unexpected classified Draw to Bitmap.")
+
+	enc := NewEncoder(nil)
+	enc.Classifier = key
+	for _, encode := range []func(*Encoder) error{
+		func(e *Encoder) error { return e.Encode(src) },
+		func(e *Encoder) error { return e.EncodeContext(context.Background(), src) },
+	} {
+		buf := bytes.NewBuffer(nil)
+		enc.w = buf
+		requireNoError(t, encode(enc), "Encode with a classifier.")
+		assertEqual(t, encodeString(t, img), buf.String(), "Unexpected classified encoding.")
+	}
+}
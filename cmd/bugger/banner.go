@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/creack/bug"
+)
+
+// bannerConfig holds the banner subcommand input flags.
+type bannerConfig struct {
+	config // Output formatting.
+
+	font        string
+	size        int
+	spacing     int
+	lineSpacing int
+	align       string
+	width       int
+	outline     bool
+	shadow      int
+}
+
+// fonts maps the embedded fonts names.
+var fonts = map[string]*bug.Font{
+	"3x5": bug.Font3x5,
+	"5x7": bug.Font5x7,
+}
+
+// aligns maps the -align flag values.
+var aligns = map[string]bug.Align{
+	"left":   bug.AlignLeft,
+	"center": bug.AlignCenter,
+	"right":  bug.AlignRight,
+}
+
+// initBannerFlags parses the banner subcommand input flags and validates them.
+func initBannerFlags(args []string) (bannerConfig, *flag.FlagSet) {
+	var cfg bannerConfig
+	fs := flag.NewFlagSet("banner", flag.ExitOnError)
+	fs.StringVar(&cfg.font, "font", "5x7", "Font: '5x7', '3x5' or the path to a BDF or PSF font file, e.g. /usr/share/consolefonts/Lat15-Terminus16.psf.gz.")
+	fs.IntVar(&cfg.size, "size", 1, "Font size, drawing each dot of the font as a size x size square.")
+	fs.IntVar(&cfg.spacing, "spacing", 0, "Extra space between the letters, in dots. Negative values tighten the text.")
+	fs.IntVar(&cfg.lineSpacing, "line-spacing", 0, "Extra space between the lines, in dots. Negative values tighten the text.")
+	fs.StringVar(&cfg.align, "align", "left", "Alignment of the lines: 'left', 'center' or 'right'.")
+	fs.IntVar(&cfg.width, "width", 0, "Maximum width, in cells, wrapping the words. 0 uses $COLUMNS, or 80. Negative disables wrapping.")
+	fs.BoolVar(&cfg.outline, "outline", false, "Draw the letters as outlines.")
+	fs.IntVar(&cfg.shadow, "shadow", 0, "Width of a drop shadow, in dots. 0 disables it.")
+	cfg.outputFlags(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bugger banner [flags] text...\n\nReads the text from stdin when missing.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args) // Exits on error.
+
+	fail := func(format string, args ...interface{}) {
+		log.Printf(format, args...)
+		fs.Usage()
+		os.Exit(1)
+	}
+	if cfg.size < 1 {
+		fail("Invalid -size %d, expected at least 1.", cfg.size)
+	}
+	if _, ok := aligns[cfg.align]; !ok {
+		fail("Invalid -align %q.", cfg.align)
+	}
+	if cfg.shadow < 0 {
+		fail("Invalid -shadow %d, expected a positive width.", cfg.shadow)
+	}
+	if msg := cfg.checkOutput(); msg != "" {
+		fail("%s", msg)
+	}
+	return cfg, fs
+}
+
+// loadFont returns the embedded font or loads the font file of the given name.
+func loadFont(name string) (*bug.Font, error) {
+	if f, ok := fonts[name]; ok {
+		return f, nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }() // Best effort.
+	if strings.HasSuffix(strings.ToLower(name), ".bdf") {
+		return bug.ParseBDF(file)
+	}
+	return bug.ParsePSF(file)
+}
+
+// terminalWidth returns the width of the terminal, in cells, from $COLUMNS, or 80.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// banner runs the banner subcommand, printing the text as braille.
+func banner(args []string) {
+	cfg, fs := initBannerFlags(args)
+
+	font, err := loadFont(cfg.font)
+	if err != nil {
+		log.Fatalf("Error loading the font %q: %s.", cfg.font, err)
+	}
+	text := strings.Join(fs.Args(), " ")
+	if fs.NArg() == 0 {
+		buf, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("Error reading the text from stdin: %s.", err)
+		}
+		text = strings.TrimRight(string(buf), "\r\n")
+	}
+
+	o := &bug.BannerOptions{
+		TextOptions: bug.TextOptions{
+			Font:        font,
+			Scale:       cfg.size,
+			Align:       aligns[cfg.align],
+			Spacing:     cfg.spacing,
+			LineSpacing: cfg.lineSpacing,
+		},
+		Outline: cfg.outline,
+		Shadow:  cfg.shadow,
+	}
+	width := cfg.width
+	if width == 0 {
+		width = terminalWidth()
+	}
+	if width > 0 {
+		// Leave room for the formatting, 2 dots per cell.
+		o.Width = 2 * (width - 2*cfg.margin - utf8.RuneCountInString(cfg.prefix))
+	}
+
+	enc := bug.NewEncoder(os.Stdout)
+	cfg.setupEncoder(enc)
+	if err := enc.Encode(bug.NewBanner(text, o)); err != nil {
+		log.Fatalf("Error encoding the banner: %s.", err)
+	}
+}
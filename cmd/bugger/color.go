@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// colorFlag holds a color flag, nil when not set.
+type colorFlag struct {
+	color.Color
+}
+
+// namedColors lists the supported color names.
+var namedColors = map[string]color.Color{
+	"white":       color.White,
+	"black":       color.Black,
+	"transparent": color.Transparent,
+}
+
+// String implements the flag.Value interface.
+func (c *colorFlag) String() string {
+	if c.Color == nil {
+		return ""
+	}
+	r, g, b, a := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x%02x", r>>8, g>>8, b>>8, a>>8)
+}
+
+// Set implements the flag.Value interface.
+// Parses a color as a name, "#rrggbb" or "#rrggbbaa", the alpha not being
+// premultiplied.
+func (c *colorFlag) Set(value string) error {
+	if named, ok := namedColors[strings.ToLower(value)]; ok {
+		c.Color = named
+		return nil
+	}
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return fmt.Errorf("invalid color %q, expected a name, #rrggbb or #rrggbbaa", value)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid color %q: %w", value, err)
+	}
+	c.Color = color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+	return nil
+}
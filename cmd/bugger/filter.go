@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/creack/bug"
+)
+
+// filterFlags collects the -filter flags, in order.
+type filterFlags []bug.Filter
+
+// filterSpecs lists the supported filters with their default arguments.
+var filterSpecs = map[string]struct {
+	defaults []float64
+	filter   func(args []float64) bug.Filter
+}{
+	"brightness": {[]float64{0}, func(a []float64) bug.Filter { return bug.Brightness(a[0]) }},
+	"contrast":   {[]float64{1}, func(a []float64) bug.Filter { return bug.Contrast(a[0]) }},
+	"gamma":      {[]float64{1}, func(a []float64) bug.Filter { return bug.Gamma(a[0]) }},
+	"levels":     {[]float64{0, 255}, func(a []float64) bug.Filter { return bug.Levels(a[0], a[1]) }},
+	"autolevels": {[]float64{0.01}, func(a []float64) bug.Filter { return bug.AutoLevels(a[0]) }},
+	"equalize":   {nil, func([]float64) bug.Filter { return bug.Equalize() }},
+	"clahe":      {[]float64{8, 3}, func(a []float64) bug.Filter { return bug.CLAHE(int(a[0]), a[1]) }},
+	"blur":       {[]float64{1}, func(a []float64) bug.Filter { return bug.Blur(a[0]) }},
+	"unsharp":    {[]float64{1, 1}, func(a []float64) bug.Filter { return bug.Unsharp(a[0], a[1]) }},
+}
+
+// String implements the flag.Value interface.
+func (f *filterFlags) String() string {
+	return fmt.Sprintf("%d filters", len(*f))
+}
+
+// Set implements the flag.Value interface.
+// Parses a filter as "name" or "name=arg[,arg]", the missing arguments using the defaults.
+func (f *filterFlags) Set(value string) error {
+	name, args := value, ""
+	if i := strings.IndexByte(value, '='); i >= 0 {
+		name, args = value[:i], value[i+1:]
+	}
+	spec, ok := filterSpecs[name]
+	if !ok {
+		return fmt.Errorf("unknown filter %q", name)
+	}
+
+	values := append([]float64(nil), spec.defaults...)
+	if args != "" {
+		parts := strings.Split(args, ",")
+		if len(parts) > len(values) {
+			return fmt.Errorf("too many arguments for the %q filter, expected up to %d", name, len(values))
+		}
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return fmt.Errorf("invalid %q filter argument %q: %w", name, part, err)
+			}
+			values[i] = v
+		}
+	}
+	*f = append(*f, spec.filter(values))
+	return nil
+}
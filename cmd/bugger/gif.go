@@ -0,0 +1,43 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+
+	"github.com/creack/bug"
+)
+
+// convertGIF composites the frames of the given GIF, honoring their
+// disposal methods, and converts each of them to BUG.
+func convertGIF(g *gif.GIF, opts *bug.Options) *bug.Animation {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewRGBA(bounds)
+	previous := image.NewRGBA(bounds)
+	anim := &bug.Animation{LoopCount: g.LoopCount}
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		anim.Image = append(anim.Image, bug.ConvertWithOptions(canvas, opts))
+		anim.Delay = append(anim.Delay, g.Delay[i])
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			copy(canvas.Pix, previous.Pix)
+		}
+	}
+	return anim
+}
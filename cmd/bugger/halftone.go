@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/creack/bug"
+)
+
+// fillOrderFlag holds the -halftone flag.
+type fillOrderFlag struct {
+	name  string
+	order bug.FillOrder
+}
+
+// fillOrders lists the built-in fill orders.
+var fillOrders = map[string]bug.FillOrder{
+	"centerout": bug.CenterOut,
+	"clustered": bug.ClusteredDot,
+	"dispersed": bug.Dispersed,
+}
+
+// brailleDots maps the braille dot numbers to their position within the cell.
+var brailleDots = map[rune]image.Point{
+	'1': {0, 0}, '2': {0, 1}, '3': {0, 2}, '4': {1, 0},
+	'5': {1, 1}, '6': {1, 2}, '7': {0, 3}, '8': {1, 3},
+}
+
+// String implements the flag.Value interface.
+func (f *fillOrderFlag) String() string {
+	return f.name
+}
+
+// Set implements the flag.Value interface.
+// Parses a fill order as a name or a custom screen listing the 8 braille
+// dot numbers in order, e.g. "25361847".
+func (f *fillOrderFlag) Set(value string) error {
+	if order, ok := fillOrders[strings.ToLower(value)]; ok {
+		f.name, f.order = value, order
+		return nil
+	}
+	var order bug.FillOrder
+	seen := map[rune]bool{}
+	for i, r := range value {
+		pt, ok := brailleDots[r]
+		if !ok || seen[r] || i >= len(order) {
+			return fmt.Errorf("invalid fill order %q, expected a name or the 8 dot numbers, e.g. 25361847", value)
+		}
+		seen[r], order[i] = true, pt
+	}
+	if len(seen) != len(order) {
+		return fmt.Errorf("invalid fill order %q, expected the 8 dot numbers, got %d", value, len(seen))
+	}
+	f.name, f.order = value, order
+	return nil
+}
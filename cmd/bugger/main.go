@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
+	"fmt"
 	"image"
+	"image/gif"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"runtime"
+	"unicode/utf8"
 
 	_ "image/jpeg"
 	_ "image/png"
@@ -13,54 +20,276 @@ import (
 	"github.com/creack/bug"
 )
 
+// config holds the cli input flags.
+type config struct {
+	threshold  int
+	inputPath  string
+	inputDots  int
+	outputPath string
+	binary     bool
+	play       bool
+	workers    int
+
+	// Rendering mode.
+	mode     string
+	detector string
+	edgeFill int
+	fit      bug.FitOptions
+	halftone fillOrderFlag
+	filters  filterFlags
+
+	// Alpha handling.
+	background  colorFlag
+	alphaCutoff uint
+
+	// Output formatting.
+	dots      int
+	crop      bool
+	trimRight bool
+	blank     string
+	crlf      bool
+	margin    int
+	prefix    string
+}
+
 // initFlags parses the cli input flags and validates them.
-func initFlags() (threshold int, inputPath, outputPath string) {
-	flag.IntVar(&threshold, "t", 100, "Threshold for conversion. Set to negative for inverse output.")
-	flag.StringVar(&inputPath, "in", "", "Path to the input image. Supports jpg/png.")
-	flag.StringVar(&outputPath, "out", "", "Target BUG file path. If missing, prints to stdout.")
+func initFlags() config {
+	var cfg config
+	flag.IntVar(&cfg.threshold, "t", 100, "Threshold for conversion. Set to negative for inverse output.")
+	flag.StringVar(&cfg.inputPath, "in", "", "Path to the input image. Supports jpg/png/gif/bug. Animated GIFs are converted to BUG animations.")
+	flag.IntVar(&cfg.inputDots, "in-dots", 8, "Dots per cell of a bug input: 8, or 6 for the output of -dots 6.")
+	flag.StringVar(&cfg.outputPath, "out", "", "Target BUG file path. If missing, prints to stdout.")
+	flag.BoolVar(&cfg.binary, "binary", false, "Use the binary variant of the BUG animation format.")
+	flag.BoolVar(&cfg.play, "play", false, "Play the input animation in the terminal instead of encoding it.")
+	flag.IntVar(&cfg.workers, "workers", runtime.GOMAXPROCS(0), "Number of goroutines converting the images, 1 converts serially.")
 
+	flag.StringVar(&cfg.mode, "mode", "fill", "Rendering mode: 'fill', 'edges' for line art, 'fit' to fit the best pattern to each cell or 'halftone'.")
+	flag.StringVar(&cfg.detector, "edges", "canny", "Edge detector for '-mode edges': 'canny' or 'sobel'.")
+	flag.IntVar(&cfg.edgeFill, "edge-fill", 0, "Threshold of a faint filled layer added to the edges. 0 disables it.")
+	flag.Float64Var(&cfg.fit.Diffusion, "fit-diffusion", 0, "Fraction, from 0 to 1, of the cell errors diffused to the next cells for '-mode fit'.")
+	flag.Float64Var(&cfg.fit.EdgeWeight, "fit-edges", 0, "Extra weight of the edges for '-mode fit', keeping them crisp.")
+	flag.Var(&cfg.halftone, "halftone", "Fill order of '-mode halftone': centerout, clustered, dispersed or the 8 braille dot numbers in order, e.g. 25361847.")
+	flag.Var(&cfg.filters, "filter", "Preprocessing filter, applied in order when repeated, as name or name=arg[,arg]: "+
+		"brightness=delta, contrast=factor, gamma=gamma, levels=black,white, autolevels=clip, equalize, "+
+		"clahe=tiles,limit, blur=sigma or unsharp=sigma,amount.")
+
+	flag.Var(&cfg.background, "bg", "Background color the transparent images are composited over: white, black, #rrggbb or #rrggbbaa.")
+	flag.UintVar(&cfg.alphaCutoff, "alpha-cutoff", 0, "Alpha, from 0 to 255, below which the pixels never set a dot. 0 disables it.")
+
+	cfg.outputFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: bugger [flags]\n       bugger banner [flags] text...\n\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	if inputPath == "" {
+	if cfg.inputPath == "" {
 		log.Printf("Missing -in.")
 		flag.Usage()
 		os.Exit(1)
 	}
+	if cfg.inputDots != 8 && cfg.inputDots != 6 {
+		log.Printf("Invalid -in-dots %d, expected 8 or 6.", cfg.inputDots)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if _, ok := modes[cfg.mode]; !ok {
+		log.Printf("Invalid -mode %q.", cfg.mode)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if _, ok := detectors[cfg.detector]; !ok {
+		log.Printf("Invalid -edges %q.", cfg.detector)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if msg := cfg.checkOutput(); msg != "" {
+		log.Print(msg)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if cfg.dots == 6 && cfg.binary {
+		log.Printf("The binary format doesn't support -dots 6.")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if cfg.alphaCutoff > 255 {
+		log.Printf("Invalid -alpha-cutoff %d, expected 0 to 255.", cfg.alphaCutoff)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	return cfg
+}
 
-	return threshold, inputPath, outputPath
+// outputFlags registers the output formatting flags on the given flag set.
+func (cfg *config) outputFlags(fs *flag.FlagSet) {
+	fs.IntVar(&cfg.dots, "dots", 8, "Dots per cell: 8, or 6 for the braille displays only showing 6 dots.")
+	fs.BoolVar(&cfg.crop, "crop", false, "Crop the output to the bounding box of the set dots.")
+	fs.BoolVar(&cfg.trimRight, "trim", false, "Trim the trailing empty cells of each line.")
+	fs.StringVar(&cfg.blank, "blank", "", "Character to use for the empty cells, e.g. ' '. Defaults to U+2800.")
+	fs.BoolVar(&cfg.crlf, "crlf", false, "Use \\r\\n line endings.")
+	fs.IntVar(&cfg.margin, "margin", 0, "Number of empty cells to add around the image.")
+	fs.StringVar(&cfg.prefix, "prefix", "", "Prefix for each line, e.g. '// '.")
+}
+
+// checkOutput validates the output formatting flags.
+// Returns the error message, or an empty string when valid.
+func (cfg config) checkOutput() string {
+	if cfg.dots != 8 && cfg.dots != 6 {
+		return fmt.Sprintf("Invalid -dots %d, expected 8 or 6.", cfg.dots)
+	}
+	if utf8.RuneCountInString(cfg.blank) > 1 {
+		return "Invalid -blank, expected a single character."
+	}
+	return ""
+}
+
+// modes maps the -mode flag values.
+var modes = map[string]bug.Mode{
+	"fill":     bug.ModeFill,
+	"edges":    bug.ModeEdges,
+	"fit":      bug.ModeFit,
+	"halftone": bug.ModeHalftone,
+}
+
+// detectors maps the -edges flag values.
+var detectors = map[string]bug.EdgeDetector{
+	"canny": bug.Canny,
+	"sobel": bug.Sobel,
+}
+
+// options returns the conversion options from the cli input flags.
+func (cfg config) options() *bug.Options {
+	return &bug.Options{
+		Threshold: bug.Threshold(cfg.threshold),
+		Workers:   cfg.workers,
+		Mode:      modes[cfg.mode],
+		Filters:   cfg.filters,
+		Edges: bug.EdgeOptions{
+			Detector: detectors[cfg.detector],
+			Fill:     bug.Threshold(cfg.edgeFill),
+		},
+		Fit:         cfg.fit,
+		Halftone:    cfg.halftone.order,
+		Background:  cfg.background.Color,
+		AlphaCutoff: uint8(cfg.alphaCutoff),
+	}
+}
+
+// setupEncoder sets the output formatting options from the cli input flags.
+func (cfg config) setupEncoder(enc *bug.Encoder) {
+	enc.Dots = cfg.dots
+	enc.Crop = cfg.crop
+	enc.TrimRight = cfg.trimRight
+	if cfg.blank != "" {
+		enc.Blank, _ = utf8.DecodeRuneInString(cfg.blank)
+	}
+	enc.CRLF = cfg.crlf
+	enc.Margin = cfg.margin
+	enc.Prefix = cfg.prefix
+}
+
+// loadAnimation decodes the given input file contents as a BUG animation
+// to be encoded with the given number of dots per cell, BUG input having
+// inputDots per cell.
+// Regular images result in single frame animations.
+func loadAnimation(buf []byte, opts *bug.Options, inputDots, dots int) (*bug.Animation, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(buf))
+	if err != nil && format == "" {
+		return nil, err
+	}
+
+	var anim *bug.Animation
+	switch format {
+	case "bug":
+		anim, err = bug.NewDecoder(bytes.NewReader(buf)).WithThreshold(opts.Threshold).WithDots(inputDots).DecodeAll()
+		if err != nil {
+			return nil, err
+		}
+		if inputDots == 6 {
+			// Already made of 6-dot cells.
+			return anim, nil
+		}
+	case "gif":
+		g, err := gif.DecodeAll(bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+		anim = convertGIF(g, opts)
+	default:
+		img, _, err := image.Decode(bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+		anim = &bug.Animation{
+			Image: []*bug.Gray{bug.ConvertWithOptions(img, opts)},
+			Delay: []int{0},
+		}
+	}
+
+	// BUG images are encoded as is, scale them to keep their aspect ratio.
+	if dots == 6 {
+		for i, img := range anim.Image {
+			anim.Image[i] = img.ScaleSixDots()
+		}
+	}
+	return anim, nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "banner" {
+		banner(os.Args[2:])
+		return
+	}
+
 	// Init the flags.
-	threshold, inputPath, outputPath := initFlags()
+	cfg := initFlags()
 
 	// Load the input image.
-	in, err := os.Open(inputPath)
+	buf, err := ioutil.ReadFile(cfg.inputPath)
 	if err != nil {
-		log.Fatalf("Error opening the input file %q: %s.", inputPath, err)
+		log.Fatalf("Error reading the input file %q: %s.", cfg.inputPath, err)
 	}
-	// Decode it in memory.
-	imgIn, _, err := image.Decode(in)
+	// Decode and convert it in memory.
+	anim, err := loadAnimation(buf, cfg.options(), cfg.inputDots, cfg.dots)
 	if err != nil {
 		log.Fatalf("Error decoding image file contents: %s.", err)
 	}
 
-	// Convert the image
-	imgOut := bug.Convert(imgIn, bug.Threshold(threshold))
+	if cfg.play {
+		enc := bug.NewEncoder(os.Stdout)
+		cfg.setupEncoder(enc)
+		if err := enc.Play(context.Background(), anim); err != nil {
+			log.Fatalf("Error playing the animation: %s.", err)
+		}
+		return
+	}
 
 	// Create the target file if needed.
 	var out io.WriteCloser
-	if outputPath != "" {
-		out, err = os.Create(outputPath)
+	if cfg.outputPath != "" {
+		out, err = os.Create(cfg.outputPath)
 		if err != nil {
-			log.Fatalf("Error creating the output file %q: %s.", outputPath, err)
+			log.Fatalf("Error creating the output file %q: %s.", cfg.outputPath, err)
 		}
 		defer func() { _ = out.Close() }() // Best effort.
 	} else {
 		out = os.Stdout
 	}
 
-	if err := bug.Encode(out, imgOut); err != nil {
-		log.Fatalf("Error encoding the result BUG image to the output file %q: %s.", outputPath, err)
+	enc := bug.NewEncoder(out).WithWorkers(cfg.workers)
+	cfg.setupEncoder(enc)
+	switch {
+	case cfg.binary:
+		err = enc.EncodeAllBinary(anim)
+	case len(anim.Image) > 1:
+		err = enc.EncodeAll(anim)
+	default:
+		err = enc.Encode(anim.Image[0])
+	}
+	if err != nil {
+		log.Fatalf("Error encoding the result BUG image to the output file %q: %s.", cfg.outputPath, err)
 	}
 }
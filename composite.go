@@ -0,0 +1,82 @@
+package bug
+
+import (
+	"image"
+)
+
+// Op is a boolean compositing operator, combining the braille dots
+// of a destination image with the ones of a source image.
+type Op int
+
+// Compositing operators.
+const (
+	// OpOr sets the dots set in either image, e.g. to overlay a grid.
+	OpOr Op = iota
+	// OpAnd keeps the dots set in both images, e.g. to apply a mask.
+	OpAnd
+	// OpXor toggles the dots set in the source.
+	OpXor
+	// OpAndNot removes the dots set in the source, e.g. to erase a shape.
+	OpAndNot
+)
+
+// apply combines the dst and src cells.
+func (op Op) apply(dst, src uint8) uint8 {
+	switch op {
+	case OpAnd:
+		return dst & src
+	case OpXor:
+		return dst ^ src
+	case OpAndNot:
+		return dst &^ src
+	default:
+		return dst | src
+	}
+}
+
+// Composite combines the dots of src with the ones of the image within r,
+// using the given operator, sp being aligned with r.Min like for Draw.
+// The dots outside the intersection of r and the translated src are left as is.
+// BUG sources (Gray and Bitmap, e.g. as a mask) use their dots, others get
+// thresholded using the image's threshold or classifier.
+// The "real" pixels of the toggled dots are updated to their canonical color.
+func (p *Gray) Composite(r image.Rectangle, src image.Image, sp image.Point, op Op) {
+	composite(p, r, src, sp, op)
+}
+
+// Composite combines the dots of src with the ones of the image within r,
+// using the given operator. See Gray.Composite.
+func (p *Bitmap) Composite(r image.Rectangle, src image.Image, sp image.Point, op Op) {
+	composite(p, r, src, sp, op)
+}
+
+// composite combines the dots of src with the ones of dst within r.
+// Operates on whole cell bytes when r and sp are aligned on the cell grid,
+// shifting the source bits otherwise.
+func composite(dst cellDrawer, r image.Rectangle, src image.Image, sp image.Point, op Op) {
+	clip(dst, &r, src, &sp)
+	if r.Empty() {
+		return
+	}
+
+	s, ok := src.(cellImage)
+	if !ok {
+		// Threshold only the needed part of the source.
+		b := NewBitmap(r.Add(sp.Sub(r.Min)))
+		b.Threshold, b.Classifier = thresholdOf(dst), classifierOf(dst)
+		Draw(b, b.Bounds(), src, b.Bounds().Min)
+		s = b
+	}
+
+	cells := cellRect(r)
+	buf := gatherCells(r, s, sp, 1)
+	for row := cells.Min.Y; row < cells.Max.Y; row++ {
+		line := buf[(row-cells.Min.Y)*cells.Dx():]
+		for col := cells.Min.X; col < cells.Max.X; col++ {
+			old := dst.cellAt(col, row)
+			cell := op.apply(old, line[col-cells.Min.X])
+			// Only update the toggled dots, keeping the others' grayscale.
+			dst.setCellBits(col, row, rectCellMask(r, col, row)&(old^cell), cell)
+		}
+	}
+}
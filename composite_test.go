@@ -0,0 +1,111 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// hasDot checks if the given pixel of img sets a braille point.
+func hasDot(img cellImage, x, y int) bool {
+	return img.cellAt(floorDiv(x, 2), floorDiv(y, 4))&unicodeOffset(x, y) != 0
+}
+
+// Test the compositing operators against a per pixel reference,
+// on aligned and unaligned offsets, views and overlapping images.
+func TestComposite(t *testing.T) {
+	ops := map[string]Op{"or": OpOr, "and": OpAnd, "xor": OpXor, "andnot": OpAndNot}
+	newNoise := func(r image.Rectangle) *Gray {
+		return Convert(newNoiseImage(func(r image.Rectangle) image.Image { return image.NewRGBA(r) }, r), DefaultThreshold)
+	}
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 16, 16),
+		image.Rect(1, 3, 15, 14),
+		image.Rect(-6, -5, 7, 9),
+		image.Rect(20, 20, 30, 30), // Outside.
+	}
+	sps := []image.Point{{0, 0}, {2, 4}, {1, 1}, {-3, 5}, {7, -2}}
+
+	for name, op := range ops {
+		for _, r := range rects {
+			for _, sp := range sps {
+				for _, srcName := range []string{"gray", "bitmap", "rgba", "self"} {
+					dst := newNoise(image.Rect(-4, -4, 20, 16))
+					var src image.Image
+					switch srcName {
+					case "gray":
+						src = newNoise(image.Rect(-3, 0, 13, 17)).SubImage(image.Rect(-1, 1, 11, 15))
+					case "bitmap":
+						src = newNoise(image.Rect(-3, 0, 13, 17)).Bitmap()
+					case "rgba":
+						src = newNoiseImage(func(r image.Rectangle) image.Image { return image.NewRGBA(r) }, image.Rect(0, 0, 12, 12))
+					case "self":
+						src = dst
+					}
+					srcDots := Convert(src, DefaultThreshold)
+					if srcName == "self" {
+						srcDots = newNoise(dst.Bounds())
+						dst.Pix, srcDots.Pix = srcDots.Pix, dst.Pix
+						dst.content, srcDots.content = srcDots.content, dst.content
+					}
+
+					// Reference, before compositing.
+					expect := map[image.Point]bool{}
+					pixels := map[image.Point]uint8{}
+					b := dst.Bounds()
+					for y := b.Min.Y; y < b.Max.Y; y++ {
+						for x := b.Min.X; x < b.Max.X; x++ {
+							p := image.Pt(x, y)
+							d := hasDot(dst, x, y)
+							pixels[p] = dst.GrayAt(x, y).Y
+							if s := p.Sub(r.Min).Add(sp); p.In(r) && s.In(src.Bounds()) {
+								var bit uint8
+								if hasDot(srcDots, s.X, s.Y) {
+									bit = 1
+								}
+								d = op.apply(boolBit(d), bit) != 0
+							}
+							expect[p] = d
+						}
+					}
+
+					dst.Composite(r, src, sp, op)
+					for p, d := range expect {
+						if hasDot(dst, p.X, p.Y) != d {
+							t.Fatalf("Unexpected dot %v for %s %s %v %v.", p, name, srcName, r, sp)
+						}
+						// Unchanged dots keep their grayscale.
+						if y := dst.GrayAt(p.X, p.Y).Y; y != pixels[p] && y != DefaultThreshold.dotColor(d).Y {
+							t.Fatalf("Unexpected pixel %v for %s %s %v %v.", p, name, srcName, r, sp)
+						}
+					}
+					assertAgree(t, dst, "Gray after %s composite.", name)
+				}
+			}
+		}
+	}
+}
+
+// boolBit returns 1 for true, 0 otherwise.
+func boolBit(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Test masking a Gray image with a Bitmap.
+func TestCompositeMask(t *testing.T) {
+	img := NewGray(image.Rect(0, 0, 8, 8))
+	img.FillCells(img.cellBounds(), 0xff)
+	img.Set(0, 0, color.Gray{Y: 0x10})
+
+	mask := NewBitmap(image.Rect(0, 0, 4, 4))
+	mask.FillCells(mask.cellBounds(), 0xff)
+	img.Composite(image.Rect(2, 4, 8, 8), mask, image.Point{}, OpAndNot)
+	assertEqual(t, "⣿⣿⣿⣿\n⣿⠀⠀⣿\n", encodeString(t, img), "Unexpected masked image.")
+	assertEqual(t, color.Gray{Y: 0x10}, img.GrayAt(0, 0), "Untouched pixel should keep its grayscale.")
+
+	img.Composite(img.Bounds(), mask, image.Pt(-1, -2), OpXor)
+	assertEqual(t, "⡟⠛⢻⣿\n⣧⠛⠃⣿\n", encodeString(t, img), "Unexpected image after xor.")
+}
@@ -0,0 +1,47 @@
+package bug
+
+// Dither is the dithering mode used to toggle the braille points.
+type Dither int
+
+// Available dithering modes.
+const (
+	// NoDither uses the threshold as is.
+	NoDither Dither = iota
+	// OrderedDither offsets the threshold per pixel using a 4x4 Bayer matrix.
+	// As each pixel only depends on its own value, cells remain independent.
+	OrderedDither
+)
+
+// bayerMatrix is the 4x4 ordered dithering index matrix.
+var bayerMatrix = [4][4]int{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// threshold returns the threshold to use for the given pixel.
+func (d Dither) threshold(t Threshold, x, y int) Threshold {
+	if d != OrderedDither {
+		return t
+	}
+
+	// Center the matrix around the threshold, spreading it over the
+	// whole gray scale: offsets range from -120 to +120.
+	offset := Threshold(bayerMatrix[y&3][x&3]*16 + 8 - 128)
+	if t < 0 {
+		return -clampThreshold(-t + offset)
+	}
+	return clampThreshold(t + offset)
+}
+
+// clampThreshold keeps the given threshold within the gray scale.
+func clampThreshold(t Threshold) Threshold {
+	if t < 1 {
+		return 1
+	}
+	if t > 0xff {
+		return 0xff
+	}
+	return t
+}
@@ -0,0 +1,219 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// cellDrawer is implemented by the BUG image types whose cells can be written directly.
+type cellDrawer interface {
+	cellImage
+	Set(x, y int, c color.Color)
+
+	// setCellBits replaces the bits of the given cell selected by mask.
+	setCellBits(col, row int, mask, bits uint8)
+	// setLumaRow sets the pixels from x, y onward to the given luminances.
+	setLumaRow(x, y int, luma []uint8)
+}
+
+// Draw copies the src image onto the dst one within r, like draw.Draw
+// with the draw.Src operator, sp being aligned with r.Min.
+//
+// When dst is a BUG image, specialized paths avoid the per pixel color
+// conversions of draw.Draw:
+//   - BUG sources are copied cell by cell, with bit masks when the
+//     offset is not aligned on the cell grid,
+//   - *image.Gray and *image.RGBA sources are thresholded straight from
+//     their Pix.
+//
+// Other combinations fall back to draw.Draw. The result is the same either way.
+func Draw(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) {
+	drawWorkers(dst, r, src, sp, 1)
+}
+
+// drawWorkers is Draw, split across the given number of goroutines
+// by bands of cell rows. See splitBands for the workers count.
+func drawWorkers(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, workers int) {
+	d, ok := dst.(cellDrawer)
+	if !ok {
+		draw.Draw(dst, r, src, sp, draw.Src)
+		return
+	}
+	clip(dst, &r, src, &sp)
+	if r.Empty() {
+		return
+	}
+
+	if s, ok := src.(cellImage); ok {
+		if t, ok := s.ColorModel().(Threshold); ok && preservesDots(t, dst) {
+			drawCells(d, r, s, sp, workers)
+			return
+		}
+		// Not split in bands as src and dst may overlap.
+		draw.Draw(dst, r, src, sp, draw.Src)
+		return
+	}
+
+	cells := cellRect(r)
+	parallelBands(cells.Min.Y, cells.Max.Y, workers, func(b band) {
+		br := r.Intersect(image.Rect(r.Min.X, b.Min*4, r.Max.X, b.Max*4))
+		drawBand(d, br, src, sp.Add(br.Min.Sub(r.Min)))
+	})
+}
+
+// drawBand draws the non BUG src onto dst within the already clipped r.
+func drawBand(dst cellDrawer, r image.Rectangle, src image.Image, sp image.Point) {
+	if classifierOf(dst) != nil {
+		// Classifiers need the full colors.
+		draw.Draw(dst, r, src, sp, draw.Src)
+		return
+	}
+	switch s := src.(type) {
+	case *image.Gray:
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := s.PixOffset(sp.X, sp.Y+y-r.Min.Y)
+			dst.setLumaRow(r.Min.X, y, s.Pix[i:i+r.Dx()])
+		}
+	case *image.RGBA:
+		luma := make([]uint8, r.Dx())
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := s.PixOffset(sp.X, sp.Y+y-r.Min.Y)
+			for x := range luma {
+				luma[x] = rgbaLuma(s.Pix[i+x*4 : i+x*4+3])
+			}
+			dst.setLumaRow(r.Min.X, y, luma)
+		}
+	default:
+		draw.Draw(dst, r, src, sp, draw.Src)
+	}
+}
+
+// clip clips r against dst's and src's bounds, updating sp accordingly.
+func clip(dst draw.Image, r *image.Rectangle, src image.Image, sp *image.Point) {
+	orig := r.Min
+	*r = r.Intersect(dst.Bounds())
+	*r = r.Intersect(src.Bounds().Add(orig.Sub(*sp)))
+	sp.X += r.Min.X - orig.X
+	sp.Y += r.Min.Y - orig.Y
+}
+
+// rgbaLuma returns the luminance of the given RGBA pixel, matching color.GrayModel.
+func rgbaLuma(pix []uint8) uint8 {
+	return luma(uint32(pix[0])*0x101, uint32(pix[1])*0x101, uint32(pix[2])*0x101)
+}
+
+// luma returns the luminance of the given 16 bits color, matching color.GrayModel.
+func luma(r, g, b uint32) uint8 {
+	return uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+}
+
+// preservesDots checks if the canonical colors of the t threshold
+// convert to the same braille points in dst, so cells can be copied as is.
+func preservesDots(t Threshold, dst image.Image) bool {
+	dt, dither := Threshold(0), NoDither
+	switch d := dst.(type) {
+	case *Gray:
+		dt, dither = d.Threshold, d.Dither
+	case *Bitmap:
+		dt = d.Threshold
+	default:
+		return false
+	}
+	if classifierOf(dst) != nil {
+		return false
+	}
+	dot, empty := t.dotColor(true).Y, t.dotColor(false).Y
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			pt := dither.threshold(dt, x, y)
+			if !pt.isDotY(dot) || pt.isDotY(empty) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Each braille cell column as a nibble, top to bottom from the lowest bit.
+// columnNibble[cell][col] extracts it, nibbleColumn[nibble][col] puts it back.
+var columnNibble, nibbleColumn = newColumnTables()
+
+func newColumnTables() (columnNibble [256][2]uint8, nibbleColumn [16][2]uint8) {
+	for cell := 0; cell < 256; cell++ {
+		for col := 0; col < 2; col++ {
+			for row := 0; row < 4; row++ {
+				if uint8(cell)&offsetMap[row][col] != 0 {
+					columnNibble[cell][col] |= 1 << uint(row)
+				}
+			}
+		}
+	}
+	for nibble := 0; nibble < 16; nibble++ {
+		for col := 0; col < 2; col++ {
+			for row := 0; row < 4; row++ {
+				if nibble&(1<<uint(row)) != 0 {
+					nibbleColumn[nibble][col] |= offsetMap[row][col]
+				}
+			}
+		}
+	}
+	return columnNibble, nibbleColumn
+}
+
+// drawCells copies the src braille points onto dst within r.
+// The already clipped r and sp don't need to be aligned on the cell grid.
+func drawCells(dst cellDrawer, r image.Rectangle, src cellImage, sp image.Point, workers int) {
+	cells := cellRect(r)
+	buf := gatherCells(r, src, sp, workers)
+	parallelBands(cells.Min.Y, cells.Max.Y, workers, func(b band) {
+		for row := b.Min; row < b.Max; row++ {
+			line := buf[(row-cells.Min.Y)*cells.Dx():]
+			for col := cells.Min.X; col < cells.Max.X; col++ {
+				dst.setCellBits(col, row, rectCellMask(r, col, row), line[col-cells.Min.X])
+			}
+		}
+	})
+}
+
+// gatherCells returns the src cells shifted to match the cells of r, sp being
+// aligned with r.Min, row by row. Gathering all of them first allows src to
+// overlap with the destination.
+// The already clipped r and sp don't need to be aligned on the cell grid.
+func gatherCells(r image.Rectangle, src cellImage, sp image.Point, workers int) []uint8 {
+	dx, dy := sp.X-r.Min.X, sp.Y-r.Min.Y
+	cells := cellRect(r)
+
+	buf := make([]uint8, cells.Dx()*cells.Dy())
+	parallelBands(cells.Min.Y, cells.Max.Y, workers, func(b band) {
+		for row := b.Min; row < b.Max; row++ {
+			line := buf[(row-cells.Min.Y)*cells.Dx():]
+			for col := cells.Min.X; col < cells.Max.X; col++ {
+				if dx%2 == 0 && dy%4 == 0 {
+					line[col-cells.Min.X] = srcCell(src, col+dx/2, row+dy/4)
+					continue
+				}
+				// Rebuild the cell column by column: each one spans 2 source cells vertically.
+				var cell uint8
+				sy := row*4 + dy
+				for c := 0; c < 2; c++ {
+					sx := col*2 + c + dx
+					scol, srow, scolumn := floorDiv(sx, 2), floorDiv(sy, 4), sx&1
+					column := uint16(columnNibble[srcCell(src, scol, srow)][scolumn]) |
+						uint16(columnNibble[srcCell(src, scol, srow+1)][scolumn])<<4
+					cell |= nibbleColumn[(column>>uint(sy&3))&0xf][c]
+				}
+				line[col-cells.Min.X] = cell
+			}
+		}
+	})
+	return buf
+}
+
+// srcCell returns the given cell of src, or an empty one when outside of it.
+func srcCell(src cellImage, col, row int) uint8 {
+	if !(image.Point{col, row}.In(src.cellBounds())) {
+		return 0
+	}
+	return src.cellAt(col, row)
+}
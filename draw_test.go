@@ -0,0 +1,156 @@
+package bug
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// assertSameImage makes sure both images have the same pixels and cells.
+func assertSameImage(tb testing.TB, expect, actual image.Image, msg string, args ...interface{}) {
+	tb.Helper()
+
+	b := expect.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if expect.At(x, y) != actual.At(x, y) {
+				assertEqual(tb, expect.At(x, y), actual.At(x, y), msg+" (pixel %d,%d)", append(args, x, y)...)
+				return
+			}
+			if g, ok := expect.(*Gray); ok && g.GrayAt(x, y) != actual.(*Gray).GrayAt(x, y) {
+				assertEqual(tb, g.GrayAt(x, y), actual.(*Gray).GrayAt(x, y), msg+" (gray %d,%d)", append(args, x, y)...)
+				return
+			}
+		}
+	}
+	if expect, actual := encodeString(tb, expect), encodeString(tb, actual); expect != actual {
+		tb.Errorf("Unexpected encoded image.\n%s", fmt.Sprintf(msg, args...))
+	}
+}
+
+// Test the Draw fast paths against the generic draw.Draw.
+func TestDraw(t *testing.T) {
+	img, _, err := image.Decode(mustGetFile(t, "testdata/biplane.png"))
+	requireNoError(t, err, "Decode testdata image.")
+	gray := img.(*image.Gray)
+
+	rgba := image.NewRGBA(image.Rect(-5, 3, 247, 255))
+	draw.Draw(rgba, rgba.Bounds(), gray, gray.Bounds().Min, draw.Src)
+	rgba.Set(10, 10, color.RGBA{R: 0x80, A: 0x80}) // Premultiplied, semi transparent.
+
+	bugGray := Convert(gray, DefaultThreshold)
+	dithered := NewGray(gray.Bounds())
+	dithered.Dither = OrderedDither
+	draw.Draw(dithered, dithered.Bounds(), gray, gray.Bounds().Min, draw.Src)
+	inverse := Convert(gray, DefaultThreshold.Inverse()).SubImage(image.Rect(3, 5, 200, 201))
+
+	sources := map[string]image.Image{
+		"gray":     gray,
+		"rgba":     rgba,
+		"bug":      bugGray,
+		"bitmap":   bugGray.Bitmap(),
+		"dithered": dithered,
+		"inverse":  inverse,
+	}
+	destinations := map[string]func() draw.Image{
+		"gray":   func() draw.Image { return NewGray(image.Rect(-3, -5, 131, 141)) },
+		"bitmap": func() draw.Image { return NewBitmap(image.Rect(-3, -5, 131, 141)) },
+		"dithered": func() draw.Image {
+			g := NewGray(image.Rect(1, 2, 100, 120))
+			g.Dither = OrderedDither
+			return g
+		},
+		"inverse": func() draw.Image {
+			g := NewGray(image.Rect(0, 0, 100, 100))
+			g.SetThreshold(DefaultThreshold.Inverse())
+			return g
+		},
+	}
+	rects := []struct {
+		r  image.Rectangle
+		sp image.Point
+	}{
+		{image.Rect(-10, -10, 300, 300), image.Pt(0, 0)},   // Aligned, clipped.
+		{image.Rect(2, 4, 100, 100), image.Pt(20, 40)},     // Aligned.
+		{image.Rect(1, -3, 97, 101), image.Pt(12, 40)},     // Unaligned rectangle.
+		{image.Rect(0, 0, 120, 130), image.Pt(33, 17)},     // Unaligned offset.
+		{image.Rect(-1, -2, 125, 135), image.Pt(-21, 250)}, // Mostly outside the source.
+	}
+
+	for sname, src := range sources {
+		for dname, newDst := range destinations {
+			for i, tc := range rects {
+				expect, actual := newDst(), newDst()
+				draw.Draw(expect, tc.r, src, tc.sp, draw.Src)
+				Draw(actual, tc.r, src, tc.sp)
+				assertSameImage(t, expect, actual, "Unexpected draw of %s on %s, case %d.", sname, dname, i)
+				if g, ok := actual.(*Gray); ok {
+					assertAgree(t, g, "Draw of %s on %s, case %d.", sname, dname, i)
+				}
+			}
+		}
+	}
+}
+
+// Test drawing a BUG image onto itself.
+func TestDrawOverlap(t *testing.T) {
+	img, _, err := image.Decode(mustGetFile(t, "testdata/biplane.png"))
+	requireNoError(t, err, "Decode testdata image.")
+
+	for _, sp := range []image.Point{{0, 0}, {3, 5}, {-7, -1}, {8, 4}} {
+		expect, actual := Convert(img, DefaultThreshold), Convert(img, DefaultThreshold)
+		r := image.Rect(10, 10, 200, 200)
+		draw.Draw(expect, r, expect, r.Min.Add(sp), draw.Src)
+		Draw(actual, r, actual, r.Min.Add(sp))
+		assertSameImage(t, expect, actual, "Unexpected overlapping draw with offset %v.", sp)
+
+		bitmap := Convert(img, DefaultThreshold).Bitmap()
+		Draw(bitmap, r, bitmap, r.Min.Add(sp))
+		assertEqual(t, encodeString(t, expect), encodeString(t, bitmap), "Unexpected overlapping bitmap draw with offset %v.", sp)
+	}
+}
+
+// benchmarkDraw draws the biplane testdata image, converted by newSrc, on a new BUG image.
+func benchmarkDraw(b *testing.B, newSrc func(image.Image) image.Image, drawFct func(dst draw.Image, src image.Image)) {
+	img, _, err := image.Decode(mustGetFile(b, "testdata/biplane.png"))
+	requireNoError(b, err, "Decode testdata image.")
+	src := newSrc(img)
+	dst := NewGray(src.Bounds())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		drawFct(dst, src)
+	}
+}
+
+func stdlibDraw(dst draw.Image, src image.Image) {
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+}
+
+func fastDraw(dst draw.Image, src image.Image) {
+	Draw(dst, dst.Bounds(), src, src.Bounds().Min)
+}
+
+func asIs(img image.Image) image.Image { return img }
+
+func toRGBA(img image.Image) image.Image {
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+func toBUG(img image.Image) image.Image { return Convert(img, DefaultThreshold) }
+
+func toBitmap(img image.Image) image.Image { return Convert(img, DefaultThreshold).Bitmap() }
+
+func BenchmarkDrawGrayStdlib(b *testing.B)   { benchmarkDraw(b, asIs, stdlibDraw) }
+func BenchmarkDrawGray(b *testing.B)         { benchmarkDraw(b, asIs, fastDraw) }
+func BenchmarkDrawRGBAStdlib(b *testing.B)   { benchmarkDraw(b, toRGBA, stdlibDraw) }
+func BenchmarkDrawRGBA(b *testing.B)         { benchmarkDraw(b, toRGBA, fastDraw) }
+func BenchmarkDrawBUGStdlib(b *testing.B)    { benchmarkDraw(b, toBUG, stdlibDraw) }
+func BenchmarkDrawBUG(b *testing.B)          { benchmarkDraw(b, toBUG, fastDraw) }
+func BenchmarkDrawBitmapStdlib(b *testing.B) { benchmarkDraw(b, toBitmap, stdlibDraw) }
+func BenchmarkDrawBitmap(b *testing.B)       { benchmarkDraw(b, toBitmap, fastDraw) }
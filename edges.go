@@ -0,0 +1,262 @@
+package bug
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Mode is the rendering mode used to convert images.
+type Mode int
+
+// Available rendering modes.
+const (
+	// ModeFill sets the dots of the pixels passing the threshold.
+	ModeFill Mode = iota
+	// ModeEdges sets the dots of the edges detected in the image,
+	// reading better than filled regions for photos. See EdgeOptions.
+	ModeEdges
+	// ModeFit sets, for each cell, the pattern closest to its pixels,
+	// preserving both its shapes and density. See FitOptions.
+	ModeFit
+	// ModeHalftone lights as many dots as the mean ink of each cell,
+	// in a fixed order. See FillOrder.
+	ModeHalftone
+)
+
+// EdgeDetector is the edge detection algorithm of the ModeEdges mode.
+type EdgeDetector int
+
+// Available edge detectors.
+const (
+	// Canny blurs the image, keeps the local maxima of the gradient
+	// and follows the edges using the hysteresis thresholds. Thin lines.
+	Canny EdgeDetector = iota
+	// Sobel keeps all the gradient magnitudes above the high threshold. Thick lines.
+	Sobel
+)
+
+// Default edge detection parameters.
+const (
+	DefaultEdgeSigma = 1.4
+	DefaultEdgeLow   = 20
+	DefaultEdgeHigh  = 50
+)
+
+// EdgeOptions are the parameters of the ModeEdges mode.
+// The zero value uses Canny with the default parameters.
+type EdgeOptions struct {
+	Detector EdgeDetector
+	// Sigma is the standard deviation of the Gaussian blur applied first.
+	// 0 means DefaultEdgeSigma for Canny and no blur for Sobel.
+	Sigma float64
+	// Low and High are the hysteresis thresholds on the gradient magnitude,
+	// expressed as the contrast of a sharp step, from 0 to 255.
+	// Edges start above High and extend above Low. Sobel only uses High.
+	// 0 means DefaultEdgeLow and DefaultEdgeHigh.
+	Low, High float64
+	// Fill adds a faint layer of dots, every other one, for the pixels passing
+	// this threshold in addition to the edges. 0 disables it.
+	Fill Threshold
+}
+
+// lumaPlane holds floating point luminances, 0 being black and 255 white.
+type lumaPlane struct {
+	pix  []float64
+	rect image.Rectangle
+}
+
+// newLumaPlane returns the luminances of img, matching color.GrayModel.
+// BUG images use their retained grayscale.
+func newLumaPlane(img image.Image) *lumaPlane {
+	var gray *image.Gray
+	switch img := img.(type) {
+	case *Gray:
+		gray = img.Gray
+	case *image.Gray:
+		gray = img
+	default:
+		gray = image.NewGray(img.Bounds())
+		draw.Draw(gray, gray.Rect, img, img.Bounds().Min, draw.Src)
+	}
+
+	b := gray.Bounds()
+	l := &lumaPlane{pix: make([]float64, b.Dx()*b.Dy()), rect: b}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := gray.Pix[gray.PixOffset(b.Min.X, y):]
+		for i := 0; i < b.Dx(); i++ {
+			l.pix[(y-b.Min.Y)*b.Dx()+i] = float64(row[i])
+		}
+	}
+	return l
+}
+
+// offset returns the index of the given pixel.
+func (l *lumaPlane) offset(x, y int) int {
+	return (y-l.rect.Min.Y)*l.rect.Dx() + x - l.rect.Min.X
+}
+
+// at returns the luminance of the given pixel, clamped to the edges of the plane.
+func (l *lumaPlane) at(x, y int) float64 {
+	if x < l.rect.Min.X {
+		x = l.rect.Min.X
+	} else if x >= l.rect.Max.X {
+		x = l.rect.Max.X - 1
+	}
+	if y < l.rect.Min.Y {
+		y = l.rect.Min.Y
+	} else if y >= l.rect.Max.Y {
+		y = l.rect.Max.Y - 1
+	}
+	return l.pix[l.offset(x, y)]
+}
+
+// gaussianBlur returns the plane blurred with the given standard deviation.
+func (l *lumaPlane) gaussianBlur(sigma float64) *lumaPlane {
+	if sigma <= 0 {
+		return l
+	}
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	// Separable: horizontal then vertical.
+	return l.convolve(kernel, 1, 0).convolve(kernel, 0, 1)
+}
+
+// convolve returns the plane convolved with the given 1D kernel along dx,dy.
+func (l *lumaPlane) convolve(kernel []float64, dx, dy int) *lumaPlane {
+	out := &lumaPlane{pix: make([]float64, len(l.pix)), rect: l.rect}
+	radius := len(kernel) / 2
+	for y := l.rect.Min.Y; y < l.rect.Max.Y; y++ {
+		for x := l.rect.Min.X; x < l.rect.Max.X; x++ {
+			v := 0.
+			for i, k := range kernel {
+				v += k * l.at(x+(i-radius)*dx, y+(i-radius)*dy)
+			}
+			out.pix[out.offset(x, y)] = v
+		}
+	}
+	return out
+}
+
+// sobel returns the gradient of the plane, scaled so a sharp step has the
+// magnitude of its contrast.
+func (l *lumaPlane) sobel() (gx, gy, mag []float64) {
+	gx, gy, mag = make([]float64, len(l.pix)), make([]float64, len(l.pix)), make([]float64, len(l.pix))
+	for y := l.rect.Min.Y; y < l.rect.Max.Y; y++ {
+		for x := l.rect.Min.X; x < l.rect.Max.X; x++ {
+			i := l.offset(x, y)
+			gx[i] = (l.at(x+1, y-1) + 2*l.at(x+1, y) + l.at(x+1, y+1) -
+				l.at(x-1, y-1) - 2*l.at(x-1, y) - l.at(x-1, y+1)) / 4
+			gy[i] = (l.at(x-1, y+1) + 2*l.at(x, y+1) + l.at(x+1, y+1) -
+				l.at(x-1, y-1) - 2*l.at(x, y-1) - l.at(x+1, y-1)) / 4
+			mag[i] = math.Hypot(gx[i], gy[i])
+		}
+	}
+	return gx, gy, mag
+}
+
+// edges returns the edge pixels of the plane, row by row.
+func (l *lumaPlane) edges(o EdgeOptions) []bool {
+	low, high, sigma := o.Low, o.High, o.Sigma
+	if low == 0 {
+		low = DefaultEdgeLow
+	}
+	if high == 0 {
+		high = DefaultEdgeHigh
+	}
+	if sigma == 0 && o.Detector == Canny {
+		sigma = DefaultEdgeSigma
+	}
+
+	gx, gy, mag := l.gaussianBlur(sigma).sobel()
+	edges := make([]bool, len(mag))
+	if o.Detector == Sobel {
+		for i, m := range mag {
+			edges[i] = m >= high
+		}
+		return edges
+	}
+
+	// Non maximum suppression: only keep the pixels stronger than both
+	// their neighbors along the gradient. Ties are broken toward the darker
+	// side so sharp steps result in 1 dot wide edges, outlining dark shapes.
+	const eps = 1e-9 // Ignores the float noise of the blur.
+	w := l.rect.Dx()
+	at := func(x, y int) float64 {
+		if !(image.Point{x, y}.In(l.rect)) {
+			return 0
+		}
+		return mag[l.offset(x, y)]
+	}
+	var stack []int
+	for y := l.rect.Min.Y; y < l.rect.Max.Y; y++ {
+		for x := l.rect.Min.X; x < l.rect.Max.X; x++ {
+			i := l.offset(x, y)
+			if mag[i] < low {
+				continue
+			}
+			// Toward the brighter side, rounded to 45°.
+			q := math.Round(math.Atan2(gy[i], gx[i])/(math.Pi/4)) * math.Pi / 4
+			dx, dy := int(math.Round(math.Cos(q))), int(math.Round(math.Sin(q)))
+			if mag[i] < at(x+dx, y+dy)-eps || mag[i] <= at(x-dx, y-dy)+eps {
+				continue
+			}
+			edges[i] = true
+			if mag[i] >= high {
+				stack = append(stack, i)
+			}
+		}
+	}
+
+	// Hysteresis: keep the weak edges connected to the strong ones.
+	strong := make([]bool, len(edges))
+	for _, i := range stack {
+		strong[i] = true
+	}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		x, y := l.rect.Min.X+i%w, l.rect.Min.Y+i/w
+		for ny := y - 1; ny <= y+1; ny++ {
+			for nx := x - 1; nx <= x+1; nx++ {
+				if !(image.Point{nx, ny}.In(l.rect)) {
+					continue
+				}
+				if j := l.offset(nx, ny); edges[j] && !strong[j] {
+					strong[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+	}
+	return strong
+}
+
+// convertEdges converts the given image using the ModeEdges mode.
+// The "real" pixels are set to the canonical colors of the dots,
+// so re-thresholding the result keeps it as is.
+func convertEdges(img image.Image, o *Options) *Gray {
+	l := newLumaPlane(img).filter(o.Filters)
+	edges := l.edges(o.Edges)
+
+	g := newGray(l.rect, o.Threshold)
+	g.Dither = o.Dither
+	for y := l.rect.Min.Y; y < l.rect.Max.Y; y++ {
+		for x := l.rect.Min.X; x < l.rect.Max.X; x++ {
+			i := l.offset(x, y)
+			dot := edges[i] || ((x+y)&1 == 0 && o.Edges.Fill.isDotY(uint8(lumaBin(l.pix[i]))))
+			g.Gray.Pix[g.Gray.PixOffset(x, y)] = g.Threshold.dotColor(dot).Y
+			g.setDot(x, y, dot)
+		}
+	}
+	return g
+}
@@ -0,0 +1,122 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// newSquareImage returns a white image with a black rectangle.
+func newSquareImage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 32, 20))
+	draw.Draw(img, img.Rect, image.NewUniform(color.Gray{Y: 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(6, 4, 26, 16), image.NewUniform(color.Gray{Y: 0}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestConvertEdges(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		opts   EdgeOptions
+		expect string
+	}{
+		{"canny", EdgeOptions{}, `
+			................................
+			................................
+			................................
+			................................
+			......####################......
+			......#..................#......
+			......#..................#......
+			......#..................#......
+			......#..................#......
+			......#..................#......
+			......#..................#......
+			......#..................#......
+			......#..................#......
+			......#..................#......
+			......#..................#......
+			......####################......
+			................................
+			................................
+			................................
+			................................
+		`},
+		{"sobel", EdgeOptions{Detector: Sobel}, `
+			................................
+			................................
+			................................
+			.....######################.....
+			.....######################.....
+			.....##..................##.....
+			.....##..................##.....
+			.....##..................##.....
+			.....##..................##.....
+			.....##..................##.....
+			.....##..................##.....
+			.....##..................##.....
+			.....##..................##.....
+			.....##..................##.....
+			.....##..................##.....
+			.....######################.....
+			.....######################.....
+			................................
+			................................
+			................................
+		`},
+		{"fill", EdgeOptions{Fill: 128}, `
+			................................
+			................................
+			................................
+			................................
+			......####################......
+			......##.#.#.#.#.#.#.#.#.#......
+			......#.#.#.#.#.#.#.#.#.##......
+			......##.#.#.#.#.#.#.#.#.#......
+			......#.#.#.#.#.#.#.#.#.##......
+			......##.#.#.#.#.#.#.#.#.#......
+			......#.#.#.#.#.#.#.#.#.##......
+			......##.#.#.#.#.#.#.#.#.#......
+			......#.#.#.#.#.#.#.#.#.##......
+			......##.#.#.#.#.#.#.#.#.#......
+			......#.#.#.#.#.#.#.#.#.##......
+			......####################......
+			................................
+			................................
+			................................
+			................................
+		`},
+	} {
+		img := ConvertWithOptions(newSquareImage(), &Options{Threshold: DefaultThreshold, Mode: ModeEdges, Edges: tt.opts})
+		assertEqual(t, dotPattern(newPatternImage(tt.expect)), dotPattern(img), "Unexpected %s edges.", tt.name)
+		assertAgree(t, img, "Gray after %s edges.", tt.name)
+	}
+}
+
+// Test the edge mode with the other conversion options.
+func TestConvertEdgesOptions(t *testing.T) {
+	src := newSquareImage()
+	expect := dotPattern(ConvertWithOptions(src, &Options{Threshold: DefaultThreshold, Mode: ModeEdges}))
+
+	// The edges don't depend on the threshold or the source type.
+	for _, o := range []*Options{
+		{Threshold: DefaultThreshold.Inverse(), Mode: ModeEdges},
+		{Threshold: DefaultThreshold, Dither: OrderedDither, Mode: ModeEdges},
+	} {
+		img := ConvertWithOptions(src, o)
+		assertEqual(t, expect, dotPattern(img), "Unexpected edges with %+v.", o)
+		assertAgree(t, img, "Gray after edges with %+v.", o)
+		// Stable once converted.
+		img.Rethreshold()
+		assertEqual(t, expect, dotPattern(img), "Unexpected edges after Rethreshold with %+v.", o)
+	}
+	bugImg := Convert(src, DefaultThreshold)
+	assertEqual(t, expect, dotPattern(ConvertWithOptions(bugImg, &Options{Threshold: DefaultThreshold, Mode: ModeEdges})), "Unexpected edges from a BUG image.")
+	assertEqual(t, dotPattern(Convert(src, DefaultThreshold)), dotPattern(bugImg), "BUG source should be left as is.")
+
+	// Flat images have no edges.
+	flat := image.NewGray(image.Rect(0, 0, 16, 8))
+	draw.Draw(flat, flat.Rect, image.NewUniform(color.Gray{Y: 0x80}), image.Point{}, draw.Src)
+	assertEqual(t, "⠀⠀⠀⠀⠀⠀⠀⠀\n⠀⠀⠀⠀⠀⠀⠀⠀\n", encodeString(t, ConvertWithOptions(flat, &Options{Threshold: DefaultThreshold, Mode: ModeEdges})), "Unexpected edges for a flat image.")
+}
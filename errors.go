@@ -0,0 +1,53 @@
+package bug
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors, to be inspected with errors.Is.
+var (
+	// ErrEmpty is returned as is, without a FormatError, when decoding an
+	// image without any cell.
+	ErrEmpty = errors.New("empty BUG image")
+
+	// ErrInvalidRune is used when a rune is not a braille cell (U+2800 - U+28FF),
+	// or not a 6-dot one (U+2800 - U+283F) when decoding 6 dots strictly.
+	ErrInvalidRune = errors.New("invalid braille rune")
+
+	// ErrRaggedRow is used when a row's width doesn't match the first row's one.
+	ErrRaggedRow = errors.New("ragged row")
+
+	// ErrDirective is used for malformed animation directives.
+	ErrDirective = errors.New("invalid directive")
+
+	// ErrInvalidFont is returned when parsing a malformed BDF or PSF font.
+	ErrInvalidFont = errors.New("invalid font")
+)
+
+// FormatError reports where a BUG image content is invalid.
+// To be inspected with errors.As.
+type FormatError struct {
+	Line   int  // 1-based line number in the stream.
+	Column int  // 1-based column, in runes. 0 when not relevant.
+	Rune   rune // Offending rune. 0 when not relevant.
+
+	Err error // Underlying cause, wrapping one of the sentinel errors.
+}
+
+func (e *FormatError) Error() string {
+	msg := fmt.Sprintf("invalid BUG image: line %d", e.Line)
+	if e.Column > 0 {
+		msg += fmt.Sprintf(", column %d", e.Column)
+	}
+	msg += ": " + e.Err.Error()
+	if e.Rune != 0 {
+		msg += fmt.Sprintf(" %q (%U)", e.Rune, e.Rune)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error, for errors.Is.
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
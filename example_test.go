@@ -13,7 +13,7 @@ import (
 )
 
 // Convert PNG to bug.
-func ExampleConvertPNGtoBUG() {
+func Example_convertPNGtoBUG() {
 	// Load a png file from the test data.
 	f, err := os.Open("./testdata/appenginegopher.png")
 	if err != nil {
@@ -0,0 +1,268 @@
+package bug
+
+import (
+	"image"
+	"math"
+)
+
+// Filter is a preprocessing step applied to the luminance of the image before
+// thresholding, set via Options.Filters. The filters are applied in order,
+// without rounding or clamping the intermediate values.
+// It can't be implemented outside of this package: use the built-in filters,
+// which are safe for concurrent use, or preprocess the image itself.
+type Filter interface {
+	filter(l *lumaPlane) *lumaPlane
+}
+
+// filterFunc implements Filter.
+type filterFunc func(l *lumaPlane) *lumaPlane
+
+func (f filterFunc) filter(l *lumaPlane) *lumaPlane { return f(l) }
+
+// pointFilter returns a Filter applying f on each pixel independently.
+func pointFilter(f func(v float64) float64) Filter {
+	return filterFunc(func(l *lumaPlane) *lumaPlane {
+		out := &lumaPlane{pix: make([]float64, len(l.pix)), rect: l.rect}
+		for i, v := range l.pix {
+			out.pix[i] = f(v)
+		}
+		return out
+	})
+}
+
+// Brightness adds delta, from -255 to 255, to the luminance.
+func Brightness(delta float64) Filter {
+	return pointFilter(func(v float64) float64 { return v + delta })
+}
+
+// Contrast scales the luminance around the mid gray by factor:
+// more than 1 increases the contrast, less than 1 decreases it.
+func Contrast(factor float64) Filter {
+	return pointFilter(func(v float64) float64 { return (v-128)*factor + 128 })
+}
+
+// Gamma applies a gamma correction: more than 1 brightens the mid tones,
+// less than 1 darkens them.
+func Gamma(gamma float64) Filter {
+	return pointFilter(func(v float64) float64 {
+		return 255 * math.Pow(clampLuma(v)/255, 1/gamma)
+	})
+}
+
+// Levels stretches the black to white luminance range to the full scale.
+func Levels(black, white float64) Filter {
+	return pointFilter(func(v float64) float64 {
+		if white <= black {
+			return v
+		}
+		return (v - black) * 255 / (white - black)
+	})
+}
+
+// AutoLevels stretches the luminance range of the image to the full scale,
+// ignoring the given fraction, e.g. 0.01, of the darkest and brightest pixels.
+func AutoLevels(clip float64) Filter {
+	return filterFunc(func(l *lumaPlane) *lumaPlane {
+		hist := l.histogram()
+		n := float64(len(l.pix))
+		black, white := 0, 255
+		for sum := 0.; black < 255; black++ {
+			if sum += hist[black]; sum > clip*n {
+				break
+			}
+		}
+		for sum := 0.; white > 0; white-- {
+			if sum += hist[white]; sum > clip*n {
+				break
+			}
+		}
+		return Levels(float64(black), float64(white)).filter(l)
+	})
+}
+
+// Equalize spreads the luminance using the histogram equalization of the image.
+func Equalize() Filter {
+	return filterFunc(func(l *lumaPlane) *lumaPlane {
+		mapping := equalization(l.histogram(), 0)
+		out := &lumaPlane{pix: make([]float64, len(l.pix)), rect: l.rect}
+		for i, v := range l.pix {
+			out.pix[i] = mapping[lumaBin(v)]
+		}
+		return out
+	})
+}
+
+// CLAHE applies a Contrast Limited Adaptive Histogram Equalization: the image
+// is split in tiles x tiles regions, equalized independently and interpolated,
+// each histogram bin being limited to clipLimit times the average, e.g. 2 to 4.
+// A clipLimit of 0 doesn't limit the contrast.
+func CLAHE(tiles int, clipLimit float64) Filter {
+	// Clamped once, as the filter may be used concurrently.
+	if tiles < 1 {
+		tiles = 1
+	}
+	return filterFunc(func(l *lumaPlane) *lumaPlane {
+		b := l.rect
+		tw, th := float64(b.Dx())/float64(tiles), float64(b.Dy())/float64(tiles)
+
+		// Equalization mapping of each tile.
+		mappings := make([][256]float64, tiles*tiles)
+		for ty := 0; ty < tiles; ty++ {
+			for tx := 0; tx < tiles; tx++ {
+				r := image.Rect(
+					b.Min.X+int(float64(tx)*tw), b.Min.Y+int(float64(ty)*th),
+					b.Min.X+int(float64(tx+1)*tw), b.Min.Y+int(float64(ty+1)*th),
+				)
+				var hist [256]float64
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					for x := r.Min.X; x < r.Max.X; x++ {
+						hist[lumaBin(l.pix[l.offset(x, y)])]++
+					}
+				}
+				mappings[ty*tiles+tx] = equalization(hist, clipLimit)
+			}
+		}
+
+		// Bilinear interpolation between the mappings of the 4 closest tile centers.
+		tile := func(t float64) (int, int, float64) {
+			t -= 0.5
+			if t < 0 {
+				return 0, 0, 0
+			}
+			if t >= float64(tiles-1) {
+				return tiles - 1, tiles - 1, 0
+			}
+			i := int(t)
+			return i, i + 1, t - float64(i)
+		}
+		out := &lumaPlane{pix: make([]float64, len(l.pix)), rect: l.rect}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			ty0, ty1, fy := tile((float64(y-b.Min.Y) + 0.5) / th)
+			for x := b.Min.X; x < b.Max.X; x++ {
+				tx0, tx1, fx := tile((float64(x-b.Min.X) + 0.5) / tw)
+				i := l.offset(x, y)
+				bin := lumaBin(l.pix[i])
+				top := mappings[ty0*tiles+tx0][bin]*(1-fx) + mappings[ty0*tiles+tx1][bin]*fx
+				bottom := mappings[ty1*tiles+tx0][bin]*(1-fx) + mappings[ty1*tiles+tx1][bin]*fx
+				out.pix[i] = top*(1-fy) + bottom*fy
+			}
+		}
+		return out
+	})
+}
+
+// Blur applies a Gaussian blur with the given standard deviation, in pixels.
+func Blur(sigma float64) Filter {
+	return filterFunc(func(l *lumaPlane) *lumaPlane { return l.gaussianBlur(sigma) })
+}
+
+// Unsharp sharpens the image using an unsharp mask: the difference with
+// the image blurred with the given standard deviation is added amount times.
+func Unsharp(sigma, amount float64) Filter {
+	return filterFunc(func(l *lumaPlane) *lumaPlane {
+		blurred := l.gaussianBlur(sigma)
+		out := &lumaPlane{pix: make([]float64, len(l.pix)), rect: l.rect}
+		for i, v := range l.pix {
+			out.pix[i] = v + amount*(v-blurred.pix[i])
+		}
+		return out
+	})
+}
+
+// filter returns the plane with the given filters applied in order.
+func (l *lumaPlane) filter(filters []Filter) *lumaPlane {
+	for _, f := range filters {
+		l = f.filter(l)
+	}
+	return l
+}
+
+// histogram returns the number of pixels per luminance.
+func (l *lumaPlane) histogram() [256]float64 {
+	var hist [256]float64
+	for _, v := range l.pix {
+		hist[lumaBin(v)]++
+	}
+	return hist
+}
+
+// equalization returns the luminance mapping equalizing the given histogram,
+// each bin being first limited to clipLimit times the average, when not 0.
+func equalization(hist [256]float64, clipLimit float64) [256]float64 {
+	total := 0.
+	for _, n := range hist {
+		total += n
+	}
+	if total == 0 {
+		var identity [256]float64
+		for i := range identity {
+			identity[i] = float64(i)
+		}
+		return identity
+	}
+	if clipLimit > 0 {
+		// Redistribute the excess uniformly.
+		limit, excess := clipLimit*total/256, 0.
+		for i, n := range hist {
+			if n > limit {
+				excess += n - limit
+				hist[i] = limit
+			}
+		}
+		for i := range hist {
+			hist[i] += excess / 256
+		}
+	}
+	// Normalized cumulative distribution, the first used bin mapping to black.
+	// Flat histograms are left as is.
+	var mapping [256]float64
+	sum, first := 0., -1.
+	for i, n := range hist {
+		sum += n
+		if first < 0 && n > 0 {
+			first = sum
+		}
+		switch {
+		case first < 0:
+		case total == first:
+			mapping[i] = float64(i)
+		default:
+			mapping[i] = 255 * (sum - first) / (total - first)
+		}
+	}
+	return mapping
+}
+
+// lumaBin returns the histogram bin of the given luminance.
+func lumaBin(v float64) int {
+	return int(math.Round(clampLuma(v)))
+}
+
+// clampLuma keeps the given luminance within the gray scale.
+func clampLuma(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+// convertFiltered converts the given image using the ModeFill mode,
+// with the preprocessing filters applied first.
+// The "real" pixels hold the filtered luminance.
+func convertFiltered(img image.Image, o *Options) *Gray {
+	l := newLumaPlane(img).filter(o.Filters)
+
+	g := newGray(l.rect, o.Threshold)
+	g.Dither = o.Dither
+	luma := make([]uint8, l.rect.Dx())
+	for y := l.rect.Min.Y; y < l.rect.Max.Y; y++ {
+		for x := range luma {
+			luma[x] = uint8(lumaBin(l.pix[l.offset(l.rect.Min.X+x, y)]))
+		}
+		g.setLumaRow(l.rect.Min.X, y, luma)
+	}
+	return g
+}
@@ -0,0 +1,88 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+	"testing"
+)
+
+// newLumaImage returns a 1 pixel high image with the given luminances.
+func newLumaImage(luma ...uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, len(luma), 1))
+	copy(img.Pix, luma)
+	return img
+}
+
+// filterLuma returns the rounded luminances of img after the given filter.
+func filterLuma(img image.Image, f Filter) []int {
+	l := newLumaPlane(img).filter([]Filter{f})
+	out := make([]int, len(l.pix))
+	for i, v := range l.pix {
+		out[i] = int(math.Round(v))
+	}
+	return out
+}
+
+func TestFilters(t *testing.T) {
+	ramp := newLumaImage(0, 64, 128, 192, 255)
+	narrow := newLumaImage(32, 32, 64, 96, 160)
+
+	for _, tt := range []struct {
+		name   string
+		img    image.Image
+		filter Filter
+		expect []int
+	}{
+		{"brightness", ramp, Brightness(10), []int{10, 74, 138, 202, 265}},
+		{"contrast", ramp, Contrast(2), []int{-128, 0, 128, 256, 382}},
+		{"gamma", ramp, Gamma(2), []int{0, 128, 181, 221, 255}},
+		{"levels", ramp, Levels(64, 192), []int{-128, 0, 128, 255, 381}},
+		{"levels invalid", ramp, Levels(64, 64), []int{0, 64, 128, 192, 255}},
+		{"autolevels", narrow, AutoLevels(0), []int{0, 0, 64, 128, 255}},
+		{"autolevels clip", narrow, AutoLevels(0.2), []int{0, 0, 128, 255, 510}},
+		{"equalize", narrow, Equalize(), []int{0, 0, 85, 170, 255}},
+		{"equalize flat", newLumaImage(50, 50), Equalize(), []int{50, 50}},
+		{"clahe", narrow, CLAHE(1, 0), []int{0, 0, 85, 170, 255}},
+		{"clahe no tiles", narrow, CLAHE(0, 0), []int{0, 0, 85, 170, 255}},
+		// Clipping redistributes over all the bins, changing flat images a bit.
+		{"clahe flat", newLumaImage(50, 50, 50, 50), CLAHE(2, 2), []int{51, 51, 51, 51}},
+		{"blur flat", newLumaImage(50, 50, 50), Blur(1), []int{50, 50, 50}},
+		{"blur", newLumaImage(0, 0, 255, 0, 0), Blur(0.5), []int{0, 27, 201, 27, 0}},
+		{"unsharp", newLumaImage(100, 100, 200, 200), Unsharp(1, 1), []int{94, 70, 230, 206}},
+	} {
+		assertEqual(t, tt.expect, filterLuma(tt.img, tt.filter), "Unexpected %s luminance.", tt.name)
+	}
+}
+
+// Test the conversion with preprocessing filters.
+func TestConvertFilters(t *testing.T) {
+	src := newLumaImage(90, 110, 130, 150, 170, 190, 210, 230)
+	opts := &Options{Threshold: 128, Filters: []Filter{Brightness(-40), Contrast(2)}}
+	assertEqual(t, "⠉⠉⠀⠀\n", encodeString(t, ConvertWithOptions(src, opts)), "Unexpected filtered conversion.")
+
+	// BUG images are left as is.
+	g := Convert(src, 128)
+	img := ConvertWithOptions(g, opts)
+	assertEqual(t, "⠉⠉⠀⠀\n", encodeString(t, img), "Unexpected filtered BUG conversion.")
+	assertEqual(t, "⠉⠀⠀⠀\n", encodeString(t, g), "BUG source should be left as is.")
+	assertEqual(t, color.Gray{Y: 52}, img.GrayAt(2, 0), "Filtered luminance should be retained.")
+	assertAgree(t, img, "Gray after filters.")
+
+	// Filters can be shared by concurrent conversions.
+	clahe := &Options{Threshold: 128, Filters: []Filter{CLAHE(0, 2)}}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ConvertWithOptions(src, clahe)
+		}()
+	}
+	wg.Wait()
+
+	// Filters are applied before the edge detection.
+	opts.Mode = ModeEdges
+	assertAgree(t, ConvertWithOptions(src, opts), "Gray after filtered edges.")
+}
@@ -0,0 +1,145 @@
+package bug
+
+import (
+	"image"
+	"sort"
+)
+
+// Cell fitting. Each cell has only 256 possible patterns: the ModeFit mode
+// picks, for each of them, the pattern closest to the cell's pixels instead
+// of thresholding the pixels independently.
+//
+// The pixels are expressed as ink, from 0 for no dot to 1 for a dot, the
+// threshold luminance being half a dot. The error of a pattern is the sum
+// of the weighted squared differences of its pixels, plus the Coverage
+// weighted squared difference of the cell's mean ink, so the density of the
+// cell is preserved as well as its shapes.
+
+// DefaultFitCoverage is the default weight of the mean ink error of a cell.
+const DefaultFitCoverage = 4
+
+// FitOptions are the parameters of the ModeFit mode.
+// The zero value uses the DefaultFitCoverage, without diffusion nor edge weighting.
+type FitOptions struct {
+	// Coverage is the weight of the cell's mean ink error relative to
+	// its pixels' errors. 0 means DefaultFitCoverage, negative disables it,
+	// which is the same as thresholding.
+	Coverage float64
+	// Diffusion is the fraction, from 0 to 1, of each cell's mean ink error
+	// diffused to its next neighbors, Floyd-Steinberg style. 0 disables it.
+	Diffusion float64
+	// EdgeWeight increases the weight of the pixels' errors by their
+	// gradient magnitude, a sharp black to white step weighing 1+EdgeWeight,
+	// keeping the edges crisp over the cell's density. 0 disables it.
+	EdgeWeight float64
+}
+
+// fitOrder lists the pixels of a cell, used to break the ties between equally
+// good pixels: the first ones are spread as a checkerboard.
+var fitOrder = Dispersed
+
+// ink returns the ink of the given luminance: 1 for a dot, 0 for none and
+// 0.5 at the threshold, linear in between. As the inverse threshold
+// luminance sets a dot, see isDotY, its 0.5 is one below it.
+func (cm Threshold) ink(v float64) float64 {
+	m := float64(uint8(cm))
+	if cm < 0 {
+		// The dots are the bright pixels.
+		v, m = 255-v, 256-m
+	}
+	v = clampLuma(v)
+	if v < m {
+		return 0.5 + 0.5*(m-v)/m
+	}
+	if m >= 255 {
+		return 0
+	}
+	return 0.5 * (255 - v) / (255 - m)
+}
+
+// fitPixel is a pixel of the cell being fitted.
+type fitPixel struct {
+	x, y int
+	// delta is the error difference between setting the dot or not.
+	delta float64
+}
+
+// convertFit converts the given image using the ModeFit mode.
+// The "real" pixels are set to the canonical colors of the dots,
+// so re-thresholding the result keeps it as is.
+func convertFit(img image.Image, o *Options) *Gray {
+	l := newLumaPlane(img).filter(o.Filters)
+	coverage := o.Fit.Coverage
+	if coverage == 0 {
+		coverage = DefaultFitCoverage
+	}
+	if coverage < 0 {
+		coverage = 0
+	}
+	var mag []float64
+	if o.Fit.EdgeWeight != 0 {
+		_, _, mag = l.sobel()
+	}
+
+	g := newGray(l.rect, o.Threshold)
+	g.Dither = o.Dither
+	cells := g.Rect
+
+	// Diffused ink errors of the current and next cell rows.
+	diffused, next := make([]float64, cells.Dx()+2), make([]float64, cells.Dx()+2)
+	pixels := make([]fitPixel, 0, 8)
+	for row := cells.Min.Y; row < cells.Max.Y; row++ {
+		for col := cells.Min.X; col < cells.Max.X; col++ {
+			// Ink and weight of each pixel, and the mean ink of the cell.
+			pixels = pixels[:0]
+			mean := 0.
+			for _, pt := range fitOrder {
+				x, y := col*2+pt.X, row*4+pt.Y
+				if !(image.Point{x, y}.In(l.rect)) {
+					continue
+				}
+				i := l.offset(x, y)
+				u, w := o.Threshold.ink(l.pix[i])+diffused[col-cells.Min.X+1], 1.
+				if mag != nil {
+					w += o.Fit.EdgeWeight * mag[i] / 255
+				}
+				// (u-1)² - u² weighted.
+				pixels = append(pixels, fitPixel{x: x, y: y, delta: w * (1 - 2*u)})
+				mean += u
+			}
+			n := float64(len(pixels))
+			mean /= n
+
+			// The best pattern with k dots sets the k pixels with the lowest
+			// deltas, so only the number of dots is left to pick.
+			sort.SliceStable(pixels, func(i, j int) bool { return pixels[i].delta < pixels[j].delta })
+			best, bestErr, sum := 0, coverage*n*mean*mean, 0.
+			for k, p := range pixels {
+				sum += p.delta
+				d := mean - float64(k+1)/n
+				if err := sum + coverage*n*d*d; err < bestErr {
+					best, bestErr = k+1, err
+				}
+			}
+			for k, p := range pixels {
+				dot := k < best
+				g.Gray.Pix[g.Gray.PixOffset(p.x, p.y)] = g.Threshold.dotColor(dot).Y
+				g.setDot(p.x, p.y, dot)
+			}
+
+			if o.Fit.Diffusion != 0 {
+				e := o.Fit.Diffusion * (mean - float64(best)/n)
+				i := col - cells.Min.X + 1
+				diffused[i+1] += e * 7 / 16
+				next[i-1] += e * 3 / 16
+				next[i] += e * 5 / 16
+				next[i+1] += e * 1 / 16
+			}
+		}
+		diffused, next = next, diffused
+		for i := range next {
+			next[i] = 0
+		}
+	}
+	return g
+}
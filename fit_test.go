@@ -0,0 +1,127 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+)
+
+// newFlatImage returns a w x h image of the given luminance.
+func newFlatImage(w, h int, y uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Rect, image.NewUniform(color.Gray{Y: y}), image.Point{}, draw.Src)
+	return img
+}
+
+// countDots returns the number of set dots of img.
+func countDots(img *Gray) int {
+	return strings.Count(dotPattern(img), "#")
+}
+
+func TestThresholdInk(t *testing.T) {
+	for _, tt := range []struct {
+		threshold Threshold
+		luma      float64
+		expect    float64
+	}{
+		{128, 0, 1},
+		{128, 64, 0.75},
+		{128, 128, 0.5},
+		{128, 255, 0},
+		{128, 300, 0},
+		{128, -10, 1},
+		{-128, 255, 1},
+		{-128, 0, 0},
+		{-128, 127, 0.5},
+		{-128, 128, 0.50390625},
+		{-1, 255, 1},
+		{-1, 254, 0.5},
+		{0, 0, 0.5},
+		{0, 255, 0},
+		{255, 255, 0},
+		{255, 0, 1},
+	} {
+		assertEqual(t, tt.expect, tt.threshold.ink(tt.luma), "Unexpected ink for %d with %d.", int(tt.luma), tt.threshold)
+	}
+}
+
+func TestConvertFit(t *testing.T) {
+	// The threshold luminance is half a cell, spread as a checkerboard.
+	img := ConvertWithOptions(newFlatImage(4, 8, 128), &Options{Threshold: 128, Mode: ModeFit})
+	assertEqual(t, "⢕⢕\n⢕⢕\n", encodeString(t, img), "Unexpected flat fit.")
+	assertAgree(t, img, "Gray after flat fit.")
+	// Stable once converted.
+	img.Rethreshold()
+	assertEqual(t, "⢕⢕\n⢕⢕\n", encodeString(t, img), "Unexpected flat fit after Rethreshold.")
+
+	// Same for the inverse, with the bright pixels, the threshold
+	// luminance being a dot.
+	img = ConvertWithOptions(newFlatImage(4, 8, 127), &Options{Threshold: -128, Mode: ModeFit})
+	assertEqual(t, "⢕⢕\n⢕⢕\n", encodeString(t, img), "Unexpected inverse flat fit.")
+	assertAgree(t, img, "Gray after inverse flat fit.")
+
+	// Without the coverage, the pixels are thresholded.
+	for _, src := range []image.Image{newSquareImage(), newFlatImage(4, 8, 128), newLogoImage()} {
+		for _, threshold := range []Threshold{DefaultThreshold, DefaultThreshold.Inverse(), 128, -128} {
+			img := ConvertWithOptions(src, &Options{Threshold: threshold, Mode: ModeFit, Fit: FitOptions{Coverage: -1}})
+			assertEqual(t, dotPattern(Convert(src, threshold)), dotPattern(img), "Unexpected fit without coverage with %d.", threshold)
+			assertAgree(t, img, "Gray after fit without coverage with %d.", threshold)
+		}
+	}
+	// Sharp images are left as is.
+	img = ConvertWithOptions(newSquareImage(), &Options{Threshold: DefaultThreshold, Mode: ModeFit})
+	assertEqual(t, dotPattern(Convert(newSquareImage(), DefaultThreshold)), dotPattern(img), "Unexpected sharp fit.")
+
+	// Partial cells only use their pixels.
+	img = ConvertWithOptions(newFlatImage(3, 5, 128), &Options{Threshold: 128, Mode: ModeFit})
+	assertEqual(t, 7, countDots(img), "Unexpected partial cells fit.")
+	assertAgree(t, img, "Gray after partial cells fit.")
+
+	// The density follows the luminance.
+	prev := 0
+	for y := 255; y >= 0; y -= 15 {
+		n := countDots(ConvertWithOptions(newFlatImage(2, 4, uint8(y)), &Options{Threshold: 128, Mode: ModeFit}))
+		if n < prev {
+			t.Fatalf("Unexpected density for %d: %d dots, less than %d for a brighter gray.", y, n, prev)
+		}
+		prev = n
+	}
+	assertEqual(t, 8, prev, "Black should set all the dots.")
+}
+
+func TestConvertFitOptions(t *testing.T) {
+	// Black and gray stripes: the coverage adds a dot to the gray column,
+	// unless the edges weigh more.
+	src := image.NewGray(image.Rect(0, 0, 2, 4))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(153 * (i % 2))
+	}
+	for _, tt := range []struct {
+		name   string
+		opts   FitOptions
+		expect string
+	}{
+		{"coverage", FitOptions{}, "⡗\n"},
+		{"edges", FitOptions{EdgeWeight: 10}, "⡇\n"},
+		{"thresholded", FitOptions{Coverage: -1}, "⡇\n"},
+	} {
+		img := ConvertWithOptions(src, &Options{Threshold: 128, Mode: ModeFit, Fit: tt.opts})
+		assertEqual(t, tt.expect, encodeString(t, img), "Unexpected %s fit.", tt.name)
+		assertAgree(t, img, "Gray after %s fit.", tt.name)
+	}
+
+	// A light gray is too light for a single dot per cell, unless the error is diffused.
+	src = newFlatImage(64, 64, 239)
+	assertEqual(t, 0, countDots(ConvertWithOptions(src, &Options{Threshold: 128, Mode: ModeFit})), "Unexpected light gray fit.")
+	img := ConvertWithOptions(src, &Options{Threshold: 128, Mode: ModeFit, Fit: FitOptions{Diffusion: 1}})
+	if n := countDots(img); n < 232 || n > 284 {
+		t.Fatalf("Unexpected light gray density with diffusion: %d dots, expected about 258.", n)
+	}
+	assertAgree(t, img, "Gray after diffused fit.")
+
+	// Filters are applied first.
+	img = ConvertWithOptions(newFlatImage(2, 4, 200), &Options{Threshold: 128, Mode: ModeFit, Filters: []Filter{Brightness(-72)}})
+	assertEqual(t, "⢕\n", encodeString(t, img), "Unexpected filtered fit.")
+}
@@ -0,0 +1,302 @@
+package bug
+
+import (
+	"image"
+	"unicode"
+)
+
+// Bitmap fonts, drawing text at dot resolution. See DrawText.
+
+// Glyph is the bitmap of a character.
+type Glyph struct {
+	// Mask holds the dots of the glyph, as non zero alphas. Its bounds are
+	// relative to the glyph's origin on the baseline, the dots above the
+	// baseline having negative y values.
+	Mask *image.Alpha
+	// Advance is the distance to the next glyph's origin, in dots,
+	// including the space between the glyphs.
+	Advance int
+}
+
+// Font is a bitmap font.
+type Font struct {
+	// Height is the distance between two lines, in dots, including the
+	// space between them.
+	Height int
+	// Ascent is the distance from the top of the lines to the baseline.
+	Ascent int
+	// Fallback, when set, provides the glyphs missing from the font.
+	// Only the first maxFallbacks fonts of the chain are looked up,
+	// so a font in its own chain doesn't loop forever.
+	Fallback *Font
+
+	glyphs map[rune]*Glyph
+}
+
+// Glyph returns the glyph of the given rune, looking it up in the
+// fallback fonts when missing. Otherwise, returns the '?' glyph, looked up
+// the same way, or nil if none.
+func (f *Font) Glyph(r rune) *Glyph {
+	if g := f.lookup(r); g != nil || r == '?' {
+		return g
+	}
+	return f.lookup('?')
+}
+
+// maxFallbacks is the maximum number of fallback fonts looked up.
+const maxFallbacks = 16
+
+// lookup returns the glyph of the given rune from the font or its
+// fallbacks, nil if missing.
+func (f *Font) lookup(r rune) *Glyph {
+	font := f
+	for i := 0; font != nil && i <= maxFallbacks; i++ {
+		if g, ok := font.glyphs[r]; ok {
+			return g
+		}
+		font = font.Fallback
+	}
+	return nil
+}
+
+// Embedded fonts, covering the printable ASCII characters.
+var (
+	// Font3x5 is a tiny font of 3x5 dots uppercase glyphs, lowercase
+	// letters being drawn as uppercase ones. 1.5 cells high.
+	Font3x5 = newFont(6, 5).addRows(3, ' ', font3x5Rows).addRows(3, '{', font3x5Symbols).foldCase()
+	// Font5x7 is the classic font of 5x7 dots glyphs. 2 cells high.
+	Font5x7 = newFont(8, 7).addColumns(5, ' ', font5x7Columns)
+)
+
+// newFont returns an empty font.
+func newFont(height, ascent int) *Font {
+	return &Font{Height: height, Ascent: ascent, glyphs: map[rune]*Glyph{}}
+}
+
+// addRows adds the glyphs of the given width and the font's ascent high,
+// starting at first, listed as their rows, the highest of the w bits on the left.
+func (f *Font) addRows(w int, first rune, rows []uint8) *Font {
+	h := f.Ascent
+	for i := 0; i*h < len(rows); i++ {
+		g := newFixedGlyph(w, h)
+		for y, row := range rows[i*h : i*h+h] {
+			for x := 0; x < w; x++ {
+				if row&(1<<uint(w-1-x)) != 0 {
+					g.Mask.Pix[g.Mask.PixOffset(x, y-h)] = 0xff
+				}
+			}
+		}
+		f.glyphs[first+rune(i)] = g
+	}
+	return f
+}
+
+// addColumns adds the glyphs of the given width and the font's ascent high,
+// starting at first, listed as their columns, the lowest bit at the top.
+func (f *Font) addColumns(w int, first rune, columns []uint8) *Font {
+	h := f.Ascent
+	for i := 0; i*w < len(columns); i++ {
+		g := newFixedGlyph(w, h)
+		for x, col := range columns[i*w : i*w+w] {
+			for y := 0; y < h; y++ {
+				if col&(1<<uint(y)) != 0 {
+					g.Mask.Pix[g.Mask.PixOffset(x, y-h)] = 0xff
+				}
+			}
+		}
+		f.glyphs[first+rune(i)] = g
+	}
+	return f
+}
+
+// foldCase adds the missing lowercase letters, using the uppercase glyphs.
+func (f *Font) foldCase() *Font {
+	for r := 'a'; r <= 'z'; r++ {
+		if _, ok := f.glyphs[r]; !ok {
+			f.glyphs[r] = f.glyphs[unicode.ToUpper(r)]
+		}
+	}
+	return f
+}
+
+// newFixedGlyph returns an empty w x h glyph sitting on the baseline,
+// followed by a column of space.
+func newFixedGlyph(w, h int) *Glyph {
+	return &Glyph{Mask: image.NewAlpha(image.Rect(0, -h, w, 0)), Advance: w + 1}
+}
+
+// font3x5Rows holds the Font3x5 glyphs, from ' ' to '`', 5 rows each.
+var font3x5Rows = []uint8{
+	0, 0, 0, 0, 0, // ' '
+	2, 2, 2, 0, 2, // '!'
+	5, 5, 0, 0, 0, // '"'
+	5, 7, 5, 7, 5, // '#'
+	3, 6, 2, 3, 6, // '$'
+	5, 1, 2, 4, 5, // '%'
+	2, 5, 2, 5, 3, // '&'
+	2, 2, 0, 0, 0, // '\''
+	1, 2, 2, 2, 1, // '('
+	4, 2, 2, 2, 4, // ')'
+	0, 5, 2, 5, 0, // '*'
+	0, 2, 7, 2, 0, // '+'
+	0, 0, 0, 2, 4, // ','
+	0, 0, 7, 0, 0, // '-'
+	0, 0, 0, 0, 2, // '.'
+	1, 1, 2, 4, 4, // '/'
+	7, 5, 5, 5, 7, // '0'
+	2, 6, 2, 2, 7, // '1'
+	7, 1, 7, 4, 7, // '2'
+	7, 1, 3, 1, 7, // '3'
+	5, 5, 7, 1, 1, // '4'
+	7, 4, 7, 1, 7, // '5'
+	7, 4, 7, 5, 7, // '6'
+	7, 1, 1, 2, 2, // '7'
+	7, 5, 7, 5, 7, // '8'
+	7, 5, 7, 1, 7, // '9'
+	0, 2, 0, 2, 0, // ':'
+	0, 2, 0, 2, 4, // ';'
+	1, 2, 4, 2, 1, // '<'
+	0, 7, 0, 7, 0, // '='
+	4, 2, 1, 2, 4, // '>'
+	7, 1, 3, 0, 2, // '?'
+	2, 5, 7, 4, 3, // '@'
+	2, 5, 7, 5, 5, // 'A'
+	6, 5, 6, 5, 6, // 'B'
+	3, 4, 4, 4, 3, // 'C'
+	6, 5, 5, 5, 6, // 'D'
+	7, 4, 6, 4, 7, // 'E'
+	7, 4, 6, 4, 4, // 'F'
+	3, 4, 5, 5, 3, // 'G'
+	5, 5, 7, 5, 5, // 'H'
+	7, 2, 2, 2, 7, // 'I'
+	1, 1, 1, 5, 2, // 'J'
+	5, 5, 6, 5, 5, // 'K'
+	4, 4, 4, 4, 7, // 'L'
+	5, 7, 7, 5, 5, // 'M'
+	6, 5, 5, 5, 5, // 'N'
+	2, 5, 5, 5, 2, // 'O'
+	6, 5, 6, 4, 4, // 'P'
+	2, 5, 5, 6, 3, // 'Q'
+	6, 5, 6, 5, 5, // 'R'
+	3, 4, 2, 1, 6, // 'S'
+	7, 2, 2, 2, 2, // 'T'
+	5, 5, 5, 5, 7, // 'U'
+	5, 5, 5, 5, 2, // 'V'
+	5, 5, 7, 7, 5, // 'W'
+	5, 5, 2, 5, 5, // 'X'
+	5, 5, 2, 2, 2, // 'Y'
+	7, 1, 2, 4, 7, // 'Z'
+	6, 4, 4, 4, 6, // '['
+	4, 4, 2, 1, 1, // '\\'
+	3, 1, 1, 1, 3, // ']'
+	2, 5, 0, 0, 0, // '^'
+	0, 0, 0, 0, 7, // '_'
+	4, 2, 0, 0, 0, // '`'
+}
+
+// font3x5Symbols holds the Font3x5 glyphs after the lowercase letters, from '{' to '~'.
+var font3x5Symbols = []uint8{
+	3, 2, 6, 2, 3, // '{'
+	2, 2, 2, 2, 2, // '|'
+	6, 2, 3, 2, 6, // '}'
+	0, 3, 6, 0, 0, // '~'
+}
+
+// font5x7Columns holds the Font5x7 glyphs, from ' ' to '~', 5 columns each.
+var font5x7Columns = []uint8{
+	0x00, 0x00, 0x00, 0x00, 0x00, // ' '
+	0x00, 0x00, 0x5f, 0x00, 0x00, // '!'
+	0x00, 0x07, 0x00, 0x07, 0x00, // '"'
+	0x14, 0x7f, 0x14, 0x7f, 0x14, // '#'
+	0x24, 0x2a, 0x7f, 0x2a, 0x12, // '$'
+	0x23, 0x13, 0x08, 0x64, 0x62, // '%'
+	0x36, 0x49, 0x55, 0x22, 0x50, // '&'
+	0x00, 0x05, 0x03, 0x00, 0x00, // '\''
+	0x00, 0x1c, 0x22, 0x41, 0x00, // '('
+	0x00, 0x41, 0x22, 0x1c, 0x00, // ')'
+	0x08, 0x2a, 0x1c, 0x2a, 0x08, // '*'
+	0x08, 0x08, 0x3e, 0x08, 0x08, // '+'
+	0x00, 0x50, 0x30, 0x00, 0x00, // ','
+	0x08, 0x08, 0x08, 0x08, 0x08, // '-'
+	0x00, 0x60, 0x60, 0x00, 0x00, // '.'
+	0x20, 0x10, 0x08, 0x04, 0x02, // '/'
+	0x3e, 0x51, 0x49, 0x45, 0x3e, // '0'
+	0x00, 0x42, 0x7f, 0x40, 0x00, // '1'
+	0x42, 0x61, 0x51, 0x49, 0x46, // '2'
+	0x21, 0x41, 0x45, 0x4b, 0x31, // '3'
+	0x18, 0x14, 0x12, 0x7f, 0x10, // '4'
+	0x27, 0x45, 0x45, 0x45, 0x39, // '5'
+	0x3c, 0x4a, 0x49, 0x49, 0x30, // '6'
+	0x01, 0x71, 0x09, 0x05, 0x03, // '7'
+	0x36, 0x49, 0x49, 0x49, 0x36, // '8'
+	0x06, 0x49, 0x49, 0x29, 0x1e, // '9'
+	0x00, 0x36, 0x36, 0x00, 0x00, // ':'
+	0x00, 0x56, 0x36, 0x00, 0x00, // ';'
+	0x08, 0x14, 0x22, 0x41, 0x00, // '<'
+	0x14, 0x14, 0x14, 0x14, 0x14, // '='
+	0x00, 0x41, 0x22, 0x14, 0x08, // '>'
+	0x02, 0x01, 0x51, 0x09, 0x06, // '?'
+	0x32, 0x49, 0x79, 0x41, 0x3e, // '@'
+	0x7e, 0x11, 0x11, 0x11, 0x7e, // 'A'
+	0x7f, 0x49, 0x49, 0x49, 0x36, // 'B'
+	0x3e, 0x41, 0x41, 0x41, 0x22, // 'C'
+	0x7f, 0x41, 0x41, 0x22, 0x1c, // 'D'
+	0x7f, 0x49, 0x49, 0x49, 0x41, // 'E'
+	0x7f, 0x09, 0x09, 0x01, 0x01, // 'F'
+	0x3e, 0x41, 0x41, 0x51, 0x32, // 'G'
+	0x7f, 0x08, 0x08, 0x08, 0x7f, // 'H'
+	0x00, 0x41, 0x7f, 0x41, 0x00, // 'I'
+	0x20, 0x40, 0x41, 0x3f, 0x01, // 'J'
+	0x7f, 0x08, 0x14, 0x22, 0x41, // 'K'
+	0x7f, 0x40, 0x40, 0x40, 0x40, // 'L'
+	0x7f, 0x02, 0x04, 0x02, 0x7f, // 'M'
+	0x7f, 0x04, 0x08, 0x10, 0x7f, // 'N'
+	0x3e, 0x41, 0x41, 0x41, 0x3e, // 'O'
+	0x7f, 0x09, 0x09, 0x09, 0x06, // 'P'
+	0x3e, 0x41, 0x51, 0x21, 0x5e, // 'Q'
+	0x7f, 0x09, 0x19, 0x29, 0x46, // 'R'
+	0x46, 0x49, 0x49, 0x49, 0x31, // 'S'
+	0x01, 0x01, 0x7f, 0x01, 0x01, // 'T'
+	0x3f, 0x40, 0x40, 0x40, 0x3f, // 'U'
+	0x1f, 0x20, 0x40, 0x20, 0x1f, // 'V'
+	0x7f, 0x20, 0x18, 0x20, 0x7f, // 'W'
+	0x63, 0x14, 0x08, 0x14, 0x63, // 'X'
+	0x03, 0x04, 0x78, 0x04, 0x03, // 'Y'
+	0x61, 0x51, 0x49, 0x45, 0x43, // 'Z'
+	0x00, 0x7f, 0x41, 0x41, 0x00, // '['
+	0x02, 0x04, 0x08, 0x10, 0x20, // '\\'
+	0x00, 0x41, 0x41, 0x7f, 0x00, // ']'
+	0x04, 0x02, 0x01, 0x02, 0x04, // '^'
+	0x40, 0x40, 0x40, 0x40, 0x40, // '_'
+	0x00, 0x01, 0x02, 0x04, 0x00, // '`'
+	0x20, 0x54, 0x54, 0x54, 0x78, // 'a'
+	0x7f, 0x48, 0x44, 0x44, 0x38, // 'b'
+	0x38, 0x44, 0x44, 0x44, 0x20, // 'c'
+	0x38, 0x44, 0x44, 0x48, 0x7f, // 'd'
+	0x38, 0x54, 0x54, 0x54, 0x18, // 'e'
+	0x08, 0x7e, 0x09, 0x01, 0x02, // 'f'
+	0x08, 0x14, 0x54, 0x54, 0x3c, // 'g'
+	0x7f, 0x08, 0x04, 0x04, 0x78, // 'h'
+	0x00, 0x44, 0x7d, 0x40, 0x00, // 'i'
+	0x20, 0x40, 0x44, 0x3d, 0x00, // 'j'
+	0x00, 0x7f, 0x10, 0x28, 0x44, // 'k'
+	0x00, 0x41, 0x7f, 0x40, 0x00, // 'l'
+	0x7c, 0x04, 0x18, 0x04, 0x78, // 'm'
+	0x7c, 0x08, 0x04, 0x04, 0x78, // 'n'
+	0x38, 0x44, 0x44, 0x44, 0x38, // 'o'
+	0x7c, 0x14, 0x14, 0x14, 0x08, // 'p'
+	0x08, 0x14, 0x14, 0x18, 0x7c, // 'q'
+	0x7c, 0x08, 0x04, 0x04, 0x08, // 'r'
+	0x48, 0x54, 0x54, 0x54, 0x20, // 's'
+	0x04, 0x3f, 0x44, 0x40, 0x20, // 't'
+	0x3c, 0x40, 0x40, 0x20, 0x7c, // 'u'
+	0x1c, 0x20, 0x40, 0x20, 0x1c, // 'v'
+	0x3c, 0x40, 0x30, 0x40, 0x3c, // 'w'
+	0x44, 0x28, 0x10, 0x28, 0x44, // 'x'
+	0x0c, 0x50, 0x50, 0x50, 0x3c, // 'y'
+	0x44, 0x64, 0x54, 0x4c, 0x44, // 'z'
+	0x00, 0x08, 0x36, 0x41, 0x00, // '{'
+	0x00, 0x00, 0x7f, 0x00, 0x00, // '|'
+	0x00, 0x41, 0x36, 0x08, 0x00, // '}'
+	0x08, 0x04, 0x08, 0x10, 0x08, // '~'
+}
@@ -0,0 +1,74 @@
+package bug
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestEmbeddedFonts(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		font           *Font
+		size           image.Point
+		height, ascent int
+	}{
+		{"3x5", Font3x5, image.Pt(3, 5), 6, 5},
+		{"5x7", Font5x7, image.Pt(5, 7), 8, 7},
+	} {
+		assertEqual(t, tt.height, tt.font.Height, "Unexpected %s height.", tt.name)
+		assertEqual(t, tt.ascent, tt.font.Ascent, "Unexpected %s ascent.", tt.name)
+
+		// All the printable ASCII characters, sitting on the baseline.
+		seen := map[string]rune{}
+		for r := ' '; r <= '~'; r++ {
+			g, ok := tt.font.glyphs[r]
+			if !ok {
+				t.Fatalf("Missing %s glyph for %q.", tt.name, r)
+			}
+			assertEqual(t, image.Rectangle{Min: image.Pt(0, -tt.size.Y), Max: image.Pt(tt.size.X, 0)}, g.Mask.Rect, "Unexpected %s %q glyph bounds.", tt.name, r)
+			assertEqual(t, tt.size.X+1, g.Advance, "Unexpected %s %q glyph advance.", tt.name, r)
+
+			// Distinct glyphs, but for the lowercase letters of Font3x5.
+			if tt.font == Font3x5 && r >= 'a' && r <= 'z' {
+				assertEqual(t, tt.font.glyphs[r-'a'+'A'], g, "Unexpected %s %q glyph.", tt.name, r)
+				continue
+			}
+			if prev, ok := seen[string(g.Mask.Pix)]; ok {
+				t.Fatalf("Same %s glyph for %q and %q.", tt.name, prev, r)
+			}
+			seen[string(g.Mask.Pix)] = r
+			if r != ' ' && bytes.Count(g.Mask.Pix, []byte{0xff}) == 0 {
+				t.Fatalf("Empty %s glyph for %q.", tt.name, r)
+			}
+		}
+	}
+}
+
+func TestFontGlyph(t *testing.T) {
+	// Missing glyphs use the '?' one.
+	assertEqual(t, Font5x7.glyphs['?'], Font5x7.Glyph('é'), "Unexpected missing glyph.")
+	assertEqual(t, Font5x7.glyphs['A'], Font5x7.Glyph('A'), "Unexpected glyph.")
+
+	// Or the fallback's ones.
+	font := newFont(10, 8)
+	font.glyphs['A'] = newFixedGlyph(6, 8)
+	font.Fallback = Font5x7
+	assertEqual(t, font.glyphs['A'], font.Glyph('A'), "Unexpected font glyph.")
+	assertEqual(t, Font5x7.glyphs['B'], font.Glyph('B'), "Unexpected fallback glyph.")
+	assertEqual(t, Font5x7.glyphs['?'], font.Glyph('é'), "Unexpected fallback missing glyph.")
+
+	// Without '?' glyph, missing glyphs are skipped.
+	font.Fallback = nil
+	if g := font.Glyph('B'); g != nil {
+		t.Fatalf("Unexpected glyph for a missing rune: %v.", g)
+	}
+
+	// Fallback cycles don't loop forever.
+	other := newFont(10, 8)
+	font.Fallback, other.Fallback = other, font
+	if g := font.Glyph('B'); g != nil {
+		t.Fatalf("Unexpected glyph for a missing rune with a fallback cycle: %v.", g)
+	}
+	assertEqual(t, font.glyphs['A'], other.Glyph('A'), "Unexpected glyph with a fallback cycle.")
+}
@@ -0,0 +1,73 @@
+package bug
+
+import "image"
+
+// Halftone. The ModeHalftone mode ignores the exact pixel positions: each
+// cell lights as many dots as its mean ink, from 0 to 8, in a fixed order.
+// The ink of the pixels is the same as the ModeFit one, the threshold
+// luminance lighting half the dots.
+
+// FillOrder lists the dots of a cell, as x,y within the cell, in the order
+// they are lit by the ModeHalftone mode, i.e. a halftone screen.
+// Each dot must be listed once, see Valid. The zero value, or an invalid
+// order, means CenterOut.
+type FillOrder [8]image.Point
+
+// Valid checks that the order lists each dot of the cell once.
+func (f FillOrder) Valid() bool {
+	var seen uint8
+	for _, pt := range f {
+		if pt.X < 0 || pt.X > 1 || pt.Y < 0 || pt.Y > 3 || seen&offsetMap[pt.Y][pt.X] != 0 {
+			return false
+		}
+		seen |= offsetMap[pt.Y][pt.X]
+	}
+	return true
+}
+
+// Built-in fill orders.
+var (
+	// CenterOut lights the center dots first, then the corners, alternating the diagonals.
+	CenterOut = FillOrder{{0, 1}, {1, 2}, {1, 1}, {0, 2}, {0, 0}, {1, 3}, {1, 0}, {0, 3}}
+	// ClusteredDot grows a single cluster from the center of the cell.
+	ClusteredDot = FillOrder{{0, 1}, {1, 1}, {1, 2}, {0, 2}, {0, 0}, {1, 0}, {1, 3}, {0, 3}}
+	// Dispersed spreads the dots as a checkerboard first.
+	Dispersed = FillOrder{{0, 0}, {1, 1}, {0, 2}, {1, 3}, {1, 0}, {0, 1}, {1, 2}, {0, 3}}
+)
+
+// convertHalftone converts the given image using the ModeHalftone mode.
+// The "real" pixels are set to the canonical colors of the dots,
+// so re-thresholding the result keeps it as is.
+func convertHalftone(img image.Image, o *Options) *Gray {
+	l := newLumaPlane(img).filter(o.Filters)
+	order := o.Halftone
+	if !order.Valid() {
+		order = CenterOut
+	}
+
+	g := newGray(l.rect, o.Threshold)
+	g.Dither = o.Dither
+	pixels := make([]image.Point, 0, len(order))
+	for row := g.Rect.Min.Y; row < g.Rect.Max.Y; row++ {
+		for col := g.Rect.Min.X; col < g.Rect.Max.X; col++ {
+			// Partial cells only light their pixels, in the same order.
+			pixels = pixels[:0]
+			ink := 0.
+			for _, pt := range order {
+				p := image.Pt(col*2+pt.X, row*4+pt.Y)
+				if !p.In(l.rect) {
+					continue
+				}
+				pixels = append(pixels, p)
+				ink += o.Threshold.ink(l.pix[l.offset(p.X, p.Y)])
+			}
+			n := int(ink + 0.5)
+			for i, p := range pixels {
+				dot := i < n
+				g.Gray.Pix[g.Gray.PixOffset(p.X, p.Y)] = g.Threshold.dotColor(dot).Y
+				g.setDot(p.X, p.Y, dot)
+			}
+		}
+	}
+	return g
+}
@@ -0,0 +1,72 @@
+package bug
+
+import (
+	"image"
+	"testing"
+)
+
+func TestConvertHalftone(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		luma   uint8
+		order  FillOrder
+		expect string
+	}{
+		{"white", 255, FillOrder{}, "⠀"},
+		{"black", 0, FillOrder{}, "⣿"},
+		{"threshold", 128, FillOrder{}, "⠶"},
+		{"threshold center out", 128, CenterOut, "⠶"},
+		{"threshold clustered", 128, ClusteredDot, "⠶"},
+		{"threshold dispersed", 128, Dispersed, "⢕"},
+		{"light center out", 192, CenterOut, "⠢"},
+		{"light clustered", 192, ClusteredDot, "⠒"},
+		{"light dispersed", 192, Dispersed, "⠑"},
+		{"light custom", 192, FillOrder{{0, 3}, {1, 3}, {0, 2}, {1, 2}, {0, 1}, {1, 1}, {0, 0}, {1, 0}}, "⣀"},
+		{"light duplicate dots", 192, FillOrder{{0, 3}, {0, 3}, {0, 2}, {1, 2}, {0, 1}, {1, 1}, {0, 0}, {1, 0}}, "⠢"},
+		{"light outside dots", 192, FillOrder{{0, 3}, {2, 3}, {0, 2}, {1, 2}, {0, 1}, {1, 1}, {0, 0}, {1, 0}}, "⠢"},
+	} {
+		img := ConvertWithOptions(newFlatImage(4, 4, tt.luma), &Options{Threshold: 128, Mode: ModeHalftone, Halftone: tt.order})
+		assertEqual(t, tt.expect+tt.expect+"\n", encodeString(t, img), "Unexpected %s halftone.", tt.name)
+		assertAgree(t, img, "Gray after %s halftone.", tt.name)
+		// Stable once converted.
+		img.Rethreshold()
+		assertEqual(t, tt.expect+tt.expect+"\n", encodeString(t, img), "Unexpected %s halftone after Rethreshold.", tt.name)
+	}
+
+	// Each dot must be listed once.
+	for _, order := range []FillOrder{CenterOut, ClusteredDot, Dispersed} {
+		assertEqual(t, true, order.Valid(), "Built-in order %v should be valid.", order)
+	}
+	assertEqual(t, false, FillOrder{}.Valid(), "The zero order should be invalid.")
+	assertEqual(t, false, FillOrder{{0, 1}, {1, 2}, {1, 1}, {0, 2}, {0, 0}, {1, 3}, {1, 0}, {0, -1}}.Valid(), "Negative dots should be invalid.")
+
+	// The density increases with the ink.
+	prev := 0
+	for y := 255; y >= 0; y -= 5 {
+		n := countDots(ConvertWithOptions(newFlatImage(2, 4, uint8(y)), &Options{Threshold: 128, Mode: ModeHalftone}))
+		if n < prev {
+			t.Fatalf("Unexpected density for %d: %d dots, less than %d for a brighter gray.", y, n, prev)
+		}
+		prev = n
+	}
+
+	// The pixel positions are ignored.
+	src := newFlatImage(2, 4, 255)
+	src.Pix[src.PixOffset(1, 3)] = 0
+	img := ConvertWithOptions(src, &Options{Threshold: 128, Mode: ModeHalftone})
+	assertEqual(t, "⠂\n", encodeString(t, img), "Unexpected single pixel halftone.")
+
+	// Inverse threshold.
+	img = ConvertWithOptions(newFlatImage(2, 4, 64), &Options{Threshold: -128, Mode: ModeHalftone})
+	assertEqual(t, "⠢\n", encodeString(t, img), "Unexpected inverse halftone.")
+	assertAgree(t, img, "Gray after inverse halftone.")
+
+	// Partial cells only use their pixels.
+	img = ConvertWithOptions(newFlatImage(3, 5, 0), &Options{Threshold: 128, Mode: ModeHalftone})
+	assertEqual(t, 15, countDots(img), "Unexpected partial cells halftone.")
+	assertAgree(t, img, "Gray after partial cells halftone.")
+	src = image.NewGray(image.Rect(1, 1, 2, 2))
+	img = ConvertWithOptions(src, &Options{Threshold: 128, Mode: ModeHalftone})
+	assertEqual(t, dotPattern(Convert(src, 128)), dotPattern(img), "Unexpected single pixel image halftone.")
+	assertAgree(t, img, "Gray after single pixel image halftone.")
+}
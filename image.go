@@ -62,6 +62,31 @@ func (cm Threshold) Inverse() Threshold {
 	return -cm
 }
 
+// isDot checks if the given color sets a braille point.
+func (cm Threshold) isDot(c color.Color) bool {
+	return cm.Convert(c) == color.Opaque
+}
+
+// isDotY checks if the given luminance sets a braille point.
+// Same as isDot for a color.Gray, without the color conversion.
+func (cm Threshold) isDotY(y uint8) bool {
+	dot := y < uint8(cm)
+	if cm < 0 {
+		return !dot
+	}
+	return dot
+}
+
+// dotColor returns the canonical gray for a set/unset braille point,
+// i.e. a color which converts back to the same state.
+func (cm Threshold) dotColor(dot bool) color.Gray {
+	// Black points on white background, or the opposite in inverse mode.
+	if dot == (cm >= 0) {
+		return color.Gray{Y: 0x00}
+	}
+	return color.Gray{Y: 0xff}
+}
+
 // Gray wraps a gray scale image with braille characters.
 // Each braille character represents 2x4 actual pixels.
 type Gray struct {
@@ -77,37 +102,64 @@ type Gray struct {
 	Rect image.Rectangle
 
 	// Threshold to toogle braille point based on gray scale.
+	// Assigning it leaves the pixels as is, see SetThreshold and Clear.
 	Threshold Threshold
+
+	// Dither mode applied on top of the threshold.
+	Dither Dither
+
+	// Classifier, when set, is used instead of Threshold and Dither to
+	// classify the colors. The retained grayscale values disagreeing with
+	// Threshold are replaced by the canonical colors of their dots, so
+	// re-thresholding with the same Threshold keeps them. As the colors
+	// are not retained, Rethreshold drops the Classifier.
+	Classifier Classifier
 }
 
 // NewGray creates a new Black and White Braille Unicode Graphic (BUG) image.
 // The rectangle is expected to be in "real" pixels.
 func NewGray(r image.Rectangle) *Gray {
-	width, height := r.Dx()/2, r.Dy()/4
-	if r.Dx()%2 != 0 {
-		width++
-	}
-	if r.Dy()%4 != 0 {
-		height++
-	}
+	return newGray(r, DefaultThreshold)
+}
+
+// newGray creates a new empty image using the given threshold, the "real"
+// pixels being set to its empty color.
+func newGray(r image.Rectangle, t Threshold) *Gray {
 	img := &Gray{
-		Gray: image.NewGray(r),
-		Rect: image.Rectangle{
-			Min: r.Min,
-			Max: image.Point{
-				X: width,  // 2 cols per cell.
-				Y: height, // 4 rows per cell.
-			},
-		},
-		Threshold: DefaultThreshold,
+		Gray:      image.NewGray(r),
+		Rect:      cellRect(r),
+		Threshold: t,
 	}
 	img.content = make([][]uint8, img.Rect.Dy())
 	for i := range img.content {
 		img.content[i] = make([]uint8, img.Rect.Dx())
 	}
+	img.clearGray()
 	return img
 }
 
+// cellRect returns the cells covering the given "real" pixel rectangle.
+// Cells are aligned on the pixel origin: cell col, row holds the pixels
+// 2*col to 2*col+1, 4*row to 4*row+3, including for negative coordinates.
+func cellRect(r image.Rectangle) image.Rectangle {
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	return image.Rect(
+		floorDiv(r.Min.X, 2), floorDiv(r.Min.Y, 4), // 2 cols per cell, 4 rows per cell.
+		-floorDiv(-r.Max.X, 2), -floorDiv(-r.Max.Y, 4),
+	)
+}
+
+// floorDiv divides a by the positive b, rounding toward negative infinity.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b < 0 {
+		q--
+	}
+	return q
+}
+
 // Clear all pixels.
 func (p *Gray) Clear() {
 	// The for loop is more efficient that a copy of empty element.
@@ -115,11 +167,84 @@ func (p *Gray) Clear() {
 	// See bench_content_test.go
 	for i, l := range p.content {
 		for j := range l {
-			p.content[i][j] = 0
+			// Only clear the pixels within the bounds, in case the
+			// image is a view sharing its edge cells with its parent.
+			p.content[i][j] &^= p.cellMask(j+p.Rect.Min.X, i+p.Rect.Min.Y)
+		}
+	}
+	p.clearGray()
+}
+
+// clearGray resets the "real" pixels to the color of an unset braille point.
+func (p *Gray) clearGray() {
+	empty := p.Threshold.dotColor(false).Y
+	for y := 0; y < p.Gray.Rect.Dy(); y++ {
+		row := p.Gray.Pix[y*p.Gray.Stride : y*p.Gray.Stride+p.Gray.Rect.Dx()]
+		for i := range row {
+			row[i] = empty
 		}
 	}
-	for i := range p.Gray.Pix {
-		p.Gray.Pix[i] = 0
+}
+
+// cellMask returns the bits of the given cell corresponding to pixels
+// within the image's bounds.
+func (p *Gray) cellMask(col, row int) uint8 {
+	return rectCellMask(p.Gray.Rect, col, row)
+}
+
+// rectCellMask returns the bits of the given cell corresponding to pixels
+// within the given "real" pixel rectangle.
+func rectCellMask(b image.Rectangle, col, row int) uint8 {
+	x0, y0 := col*2, row*4 // Pixel origin of the cell.
+	if x0 >= b.Min.X && x0+2 <= b.Max.X && y0 >= b.Min.Y && y0+4 <= b.Max.Y {
+		return 0xff
+	}
+	var mask uint8
+	for y := y0; y < y0+4; y++ {
+		for x := x0; x < x0+2; x++ {
+			if (image.Point{x, y}).In(b) {
+				mask |= unicodeOffset(x, y)
+			}
+		}
+	}
+	return mask
+}
+
+// cellBounds implements the cellImage interface.
+func (p *Gray) cellBounds() image.Rectangle {
+	return p.Rect
+}
+
+// cellAt implements the cellImage interface.
+func (p *Gray) cellAt(col, row int) uint8 {
+	return p.content[row-p.Rect.Min.Y][col-p.Rect.Min.X] & p.cellMask(col, row)
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value, a *Gray, shares pixels and cells with the
+// original image.
+func (p *Gray) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Gray.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed to be inside
+	// either r1 or r2 if the intersection is empty. Without explicitly checking for
+	// this, the Pix[i:] expression below can panic.
+	if r.Empty() {
+		return &Gray{Gray: &image.Gray{}, Threshold: p.Threshold, Dither: p.Dither, Classifier: p.Classifier}
+	}
+
+	cells := cellRect(r)
+	content := make([][]uint8, cells.Dy())
+	for i := range content {
+		row := p.content[cells.Min.Y-p.Rect.Min.Y+i]
+		content[i] = row[cells.Min.X-p.Rect.Min.X : cells.Max.X-p.Rect.Min.X]
+	}
+	return &Gray{
+		Gray:       p.Gray.SubImage(r).(*image.Gray),
+		content:    content,
+		Rect:       cells,
+		Threshold:  p.Threshold,
+		Dither:     p.Dither,
+		Classifier: p.Classifier,
 	}
 }
 
@@ -130,7 +255,80 @@ func (p *Gray) BrailleAt(col, row int) rune {
 		return brailleCharOffset
 	}
 
-	return rune(p.content[row][col]) + brailleCharOffset
+	return rune(p.cellAt(col, row)) + brailleCharOffset
+}
+
+// At implements the image.Image interface.
+// Returns the canonical color of the braille point for the given pixel,
+// so it always agrees with BrailleAt. The retained grayscale value
+// is available via GrayAt.
+func (p *Gray) At(x, y int) color.Color {
+	return p.dotAt(x, y)
+}
+
+// RGBA64At implements the image.RGBA64Image interface.
+// Shadows the embedded image.Gray one, see At.
+func (p *Gray) RGBA64At(x, y int) color.RGBA64 {
+	r, g, b, a := p.dotAt(x, y).RGBA()
+	return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+}
+
+// dotAt returns the canonical color of the braille point for the given pixel.
+func (p *Gray) dotAt(x, y int) color.Gray {
+	if !(image.Point{x, y}.In(p.Gray.Rect)) {
+		return color.Gray{}
+	}
+	return p.Threshold.dotColor(p.isSet(x, y))
+}
+
+// isSet checks if the braille point of the given "real" pixel x,y is set.
+func (p *Gray) isSet(x, y int) bool {
+	return p.content[floorDiv(y, 4)-p.Rect.Min.Y][floorDiv(x, 2)-p.Rect.Min.X]&unicodeOffset(x, y) != 0
+}
+
+// setDot sets or removes the braille point of the given "real" pixel x,y.
+func (p *Gray) setDot(x, y int, dot bool) {
+	cell := &p.content[floorDiv(y, 4)-p.Rect.Min.Y][floorDiv(x, 2)-p.Rect.Min.X]
+	if dot {
+		*cell |= unicodeOffset(x, y)
+	} else {
+		*cell &^= unicodeOffset(x, y)
+	}
+}
+
+// setCellBits implements the cellDrawer interface.
+// Updates the "real" pixels of the masked bits to their canonical color.
+func (p *Gray) setCellBits(col, row int, mask, bits uint8) {
+	cell := &p.content[row-p.Rect.Min.Y][col-p.Rect.Min.X]
+	*cell = *cell&^mask | bits&mask
+	for y := row * 4; y < row*4+4; y++ {
+		for x := col * 2; x < col*2+2; x++ {
+			if mask&unicodeOffset(x, y) != 0 {
+				p.Gray.Pix[p.Gray.PixOffset(x, y)] = p.Threshold.dotColor(bits&unicodeOffset(x, y) != 0).Y
+			}
+		}
+	}
+}
+
+// setLumaRow implements the cellDrawer interface.
+// Same as calling Set for each pixel with a color.Gray.
+func (p *Gray) setLumaRow(x, y int, luma []uint8) {
+	if p.Classifier != nil {
+		for i, l := range luma {
+			p.Set(x+i, y, color.Gray{Y: l})
+		}
+		return
+	}
+	copy(p.Gray.Pix[p.Gray.PixOffset(x, y):], luma)
+	if p.Dither == NoDither {
+		for i, l := range luma {
+			p.setDot(x+i, y, p.Threshold.isDotY(l))
+		}
+		return
+	}
+	for i, l := range luma {
+		p.setDot(x+i, y, p.Dither.threshold(p.Threshold, x+i, y).isDotY(l))
+	}
 }
 
 // Set implements the image.Image interface.
@@ -140,24 +338,83 @@ func (p *Gray) Set(x, y int, c color.Color) {
 	if !(image.Point{x, y}.In(p.Gray.Rect)) {
 		return
 	}
-	p.Gray.Set(x, y, c)
-	p.SetBraille(x, y, p.ColorModel().Convert(c))
+	t := p.Dither.threshold(p.Threshold, x, y)
+	dot := p.classify(t, c)
+	// Keep the grayscale value, unless it disagrees with the braille point
+	// (i.e. the color model's special cases), to be able to re-threshold it.
+	g := color.GrayModel.Convert(c).(color.Gray)
+	if t.isDot(g) != dot {
+		g = p.Threshold.dotColor(dot)
+	}
+	p.Gray.SetGray(x, y, g)
+	p.setDot(x, y, dot)
+}
+
+// classify checks if the given color sets a dot, using the Classifier
+// if any, the given pixel threshold otherwise.
+func (p *Gray) classify(t Threshold, c color.Color) bool {
+	if p.Classifier != nil {
+		return p.Classifier.IsDot(c)
+	}
+	return t.isDot(c)
+}
+
+// SetThreshold updates the threshold and re-derives all the braille
+// points from the retained grayscale image.
+func (p *Gray) SetThreshold(t Threshold) {
+	p.Threshold = t
+	p.Rethreshold()
+}
+
+// SetDither updates the dithering mode and re-derives all the braille
+// points from the retained grayscale image.
+func (p *Gray) SetDither(d Dither) {
+	p.Dither = d
+	p.Rethreshold()
+}
+
+// Rethreshold re-derives all the braille points from the retained
+// grayscale image using the current Threshold and Dither.
+// To be called after updating them directly.
+// The Classifier, if any, is dropped as it needs the original colors.
+func (p *Gray) Rethreshold() {
+	p.rethreshold(1)
+}
+
+// rethreshold is Rethreshold, split across the given number of goroutines.
+func (p *Gray) rethreshold(workers int) {
+	p.Classifier = nil
+	parallelBands(p.Rect.Min.Y, p.Rect.Max.Y, workers, func(b band) {
+		r := p.Gray.Rect.Intersect(image.Rect(p.Gray.Rect.Min.X, b.Min*4, p.Gray.Rect.Max.X, b.Max*4))
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				p.setDot(x, y, p.Dither.threshold(p.Threshold, x, y).isDot(p.Gray.GrayAt(x, y)))
+			}
+		}
+	})
+}
+
+// SetRGBA64 implements the draw.RGBA64Image interface.
+// Shadows the embedded image.Gray one, used by draw.Draw's fast path,
+// so the braille mapping gets updated as well.
+func (p *Gray) SetRGBA64(x, y int, c color.RGBA64) {
+	p.Set(x, y, c)
 }
 
 // ColorModel implements the image.Image interface. It defines the
-// grayscale threshold when to set the braille character point.
+// grayscale threshold when to set the braille character point,
+// or the Classifier when set.
 func (p *Gray) ColorModel() color.Model {
-	return p.Threshold
+	return colorModel(p.Threshold, p.Classifier)
 }
 
 // SetBraille updates the cell with the given "real" pixel x,y.
 func (p *Gray) SetBraille(x, y int, c color.Color) {
-	col, row := x/2, y/4
+	// Discard pixels outside the image.
+	if !(image.Point{x, y}.In(p.Gray.Rect)) {
+		return
+	}
 
 	// If opaque, set the point, otherwise, remove it.
-	if c == color.Opaque {
-		p.content[row][col] |= unicodeOffset(x, y)
-	} else {
-		p.content[row][col] &^= unicodeOffset(x, y)
-	}
+	p.setDot(x, y, c == color.Opaque)
 }
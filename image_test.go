@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	// Import the common image formats to make sure we don't
@@ -119,6 +122,226 @@ func TestStdlibFormats(t *testing.T) {
 	t.Run("jpeg", func(t *testing.T) { loadImage(t, "testdata/video-001.jpeg", "jpeg") })
 }
 
+// assertAgree makes sure At and BrailleAt agree for every pixel of the image.
+func assertAgree(tb testing.TB, img *Gray, msg string, args ...interface{}) {
+	tb.Helper()
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cell := uint8(img.BrailleAt(floorDiv(x, 2), floorDiv(y, 4)) - brailleCharOffset)
+			dot := cell&unicodeOffset(x, y) != 0
+			threshold := img.Dither.threshold(img.Threshold, x, y)
+			if threshold.isDot(img.At(x, y)) != dot {
+				tb.Fatalf("At and BrailleAt disagree at %d,%d (cell %#02x).\n%s", x, y, cell, fmt.Sprintf(msg, args...))
+			}
+			// The retained grayscale value should convert to the same point as well.
+			if threshold.isDot(img.GrayAt(x, y)) != dot {
+				tb.Fatalf("GrayAt and BrailleAt disagree at %d,%d (cell %#02x).\n%s", x, y, cell, fmt.Sprintf(msg, args...))
+			}
+		}
+	}
+}
+
+// Test all the cell values through decode, Set, Clear and conversion.
+func TestCellFidelity(t *testing.T) {
+	cellFidelity := func(t *testing.T, threshold Threshold) {
+		for v := 0; v < 256; v++ {
+			cell := string(brailleCharOffset + rune(v))
+
+			// Decode.
+			decoded, err := NewDecoder(strings.NewReader(cell)).WithThreshold(threshold).Decode()
+			requireNoError(t, err, "Decode cell %#02x.", v)
+			img := decoded.(*Gray)
+			assertEqual(t, cell, string(img.BrailleAt(0, 0)), "Unexpected decoded cell.")
+			assertAgree(t, img, "Decoded cell %#02x.", v)
+
+			// Conversion through other image types.
+			rgba := image.NewRGBA(img.Bounds())
+			draw.Draw(rgba, rgba.Bounds(), img, image.Point{}, draw.Src)
+			converted := Convert(rgba, threshold)
+			assertEqual(t, cell, string(converted.BrailleAt(0, 0)), "Unexpected converted cell.")
+			assertAgree(t, converted, "Converted cell %#02x.", v)
+
+			// Set, with the various colors the model special cases.
+			for _, colors := range [][2]color.Color{
+				{threshold.dotColor(true), threshold.dotColor(false)},
+				{threshold.Convert(color.Opaque), threshold.Convert(color.Transparent)},
+				{threshold.Convert(color.White), threshold.Convert(color.Black)},
+			} {
+				set := NewGray(img.Bounds())
+				set.Threshold = threshold
+				for y := 0; y < 4; y++ {
+					for x := 0; x < 2; x++ {
+						if v&int(unicodeOffset(x, y)) != 0 {
+							set.Set(x, y, colors[0])
+						} else {
+							set.Set(x, y, colors[1])
+						}
+					}
+				}
+				assertEqual(t, cell, string(set.BrailleAt(0, 0)), "Unexpected set cell with %v.", colors)
+				assertAgree(t, set, "Set cell %#02x with %v.", v, colors)
+
+				// Clear.
+				set.Clear()
+				assertEqual(t, string(brailleCharOffset), string(set.BrailleAt(0, 0)), "Unexpected cleared cell.")
+				assertAgree(t, set, "Cleared cell %#02x.", v)
+			}
+		}
+	}
+	t.Run("default", func(t *testing.T) { cellFidelity(t, DefaultThreshold) })
+	t.Run("inverse", func(t *testing.T) { cellFidelity(t, DefaultThreshold.Inverse()) })
+}
+
+// Test decoding .bug file, going through .png and converting it back.
+func TestDecodePNGRoundTrip(t *testing.T) {
+	roundTrip := func(t *testing.T, name string, threshold Threshold) {
+		expect := mustGetFile(t, "testdata/"+name+".bug")
+		img, err := NewDecoder(mustGetFile(t, "testdata/"+name+".bug")).WithThreshold(threshold).Decode()
+		requireNoError(t, err, "Decode testdata image %q.", name)
+		assertAgree(t, img.(*Gray), "Decoded image %q.", name)
+
+		pngBuf := bytes.NewBuffer(nil)
+		requireNoError(t, png.Encode(pngBuf, img), "Encode decoded image to png %q.", name)
+		pngImg, err := png.Decode(pngBuf)
+		requireNoError(t, err, "Decode png image %q.", name)
+
+		converted := Convert(pngImg, threshold)
+		assertAgree(t, converted, "Converted image %q.", name)
+		assertEqual(t, expect, encodeString(t, converted), "Unexpected round trip image.")
+	}
+	for _, name := range []string{"appenginegopher", "biplane"} {
+		name := name
+		t.Run(name, func(t *testing.T) { roundTrip(t, name, DefaultThreshold) })
+		t.Run(name+"-inverse", func(t *testing.T) { roundTrip(t, name, DefaultThreshold.Inverse()) })
+	}
+}
+
+// Test updating the threshold of an existing image.
+func TestRethreshold(t *testing.T) {
+	rethreshold := func(t *testing.T, name string) {
+		img, _, err := image.Decode(mustGetFile(t, "testdata/"+name+".png"))
+		requireNoError(t, err, "Decode testdata image %q.", name)
+		g := Convert(img, DefaultThreshold)
+
+		g.SetThreshold(DefaultThreshold.Inverse())
+		assertAgree(t, g, "Inverse image %q.", name)
+		assertEqual(t, mustGetFile(t, "testdata/"+name+".inverse.bug"), encodeString(t, g), "Unexpected inverse image.")
+
+		// Converting an existing BUG image should re-threshold it as well.
+		assertEqual(t, g, Convert(g, DefaultThreshold), "Convert should update the image in place.")
+		assertAgree(t, g, "Back to default image %q.", name)
+		assertEqual(t, mustGetFile(t, "testdata/"+name+".bug"), encodeString(t, g), "Unexpected default image.")
+
+		// Dithering should yield the same result as setting the pixels with dithering enabled.
+		g.SetDither(OrderedDither)
+		assertAgree(t, g, "Dithered image %q.", name)
+		dithered := NewGray(img.Bounds())
+		dithered.Dither = OrderedDither
+		draw.Draw(dithered, dithered.Bounds(), img, img.Bounds().Min, draw.Src)
+		assertEqual(t, encodeString(t, dithered), encodeString(t, g), "Unexpected dithered image.")
+		if encodeString(t, g) == mustGetFile(t, "testdata/"+name+".bug").String() {
+			t.Error("Dithering should alter the image.")
+		}
+		assertEqual(t, encodeString(t, dithered), encodeString(t, Convert(g, DefaultThreshold)), "Convert should keep the image's dither.")
+
+		// Updating the fields directly and re-thresholding.
+		g.Threshold, g.Dither = DefaultThreshold, NoDither
+		g.Rethreshold()
+		assertEqual(t, mustGetFile(t, "testdata/"+name+".bug"), encodeString(t, g), "Unexpected re-thresholded image.")
+	}
+	t.Run("appenginegopher", func(t *testing.T) { rethreshold(t, "appenginegopher") })
+	t.Run("biplane", func(t *testing.T) { rethreshold(t, "biplane") })
+}
+
+// Test images with bounds not starting at 0,0.
+func TestNonZeroOrigin(t *testing.T) {
+	nonZeroOrigin := func(t *testing.T, r image.Rectangle) {
+		img := NewGray(r)
+		expect := NewGray(image.Rect(0, 0, r.Dx(), r.Dy()))
+		assertEqual(t, r, img.Bounds(), "Unexpected bounds.")
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				c := color.Gray{Y: uint8((x*7 + y*13) * 5)}
+				img.Set(x, y, c)
+				expect.Set(x-r.Min.X, y-r.Min.Y, c)
+			}
+		}
+		assertAgree(t, img, "Image %v.", r)
+		assertEqual(t, encodeString(t, expect), encodeString(t, img), "Unexpected encoded image %v.", r)
+
+		// Outside pixels are discarded.
+		img.Set(r.Min.X-1, r.Min.Y-1, color.Black)
+		img.Set(r.Max.X, r.Max.Y, color.Black)
+		assertEqual(t, color.Gray{}, img.At(r.Max.X, r.Max.Y), "Unexpected pixel outside bounds.")
+		assertEqual(t, encodeString(t, expect), encodeString(t, img), "Unexpected encoded image %v.", r)
+	}
+	for _, r := range []image.Rectangle{
+		image.Rect(2, 4, 10, 12),
+		image.Rect(1, 3, 10, 12),
+		image.Rect(-3, -5, 7, 6),
+		image.Rect(-8, -8, -1, -2),
+	} {
+		r := r
+		t.Run(r.String(), func(t *testing.T) { nonZeroOrigin(t, r) })
+	}
+}
+
+// Test SubImage views.
+func TestSubImage(t *testing.T) {
+	subImage := func(t *testing.T, r image.Rectangle) {
+		decoded, err := Decode(mustGetFile(t, "testdata/biplane.bug"))
+		requireNoError(t, err, "Decode testdata image.")
+		parent := decoded.(*Gray)
+
+		// Compare with the stdlib's SubImage.
+		rgba := image.NewRGBA(parent.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), parent, image.Point{}, draw.Src)
+		expect := Convert(rgba.SubImage(r), DefaultThreshold)
+
+		sub := parent.SubImage(r).(*Gray)
+		assertEqual(t, r.Intersect(parent.Bounds()), sub.Bounds(), "Unexpected bounds.")
+		assertAgree(t, sub, "SubImage %v.", r)
+		assertEqual(t, encodeString(t, expect), encodeString(t, sub), "Unexpected encoded sub image.")
+
+		// Storage is shared with the parent.
+		before := image.NewRGBA(parent.Bounds())
+		draw.Draw(before, before.Bounds(), parent, image.Point{}, draw.Src)
+		sub.Clear()
+		b := parent.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				expect := color.Color(parent.Threshold.dotColor(false))
+				if !(image.Point{x, y}).In(sub.Bounds()) {
+					expect = before.At(x, y)
+				}
+				if parent.Threshold.isDot(expect) != parent.Threshold.isDot(parent.At(x, y)) {
+					t.Fatalf("Unexpected parent pixel at %d,%d after clearing the sub image.", x, y)
+				}
+			}
+		}
+		assertAgree(t, parent, "Parent of cleared %v.", r)
+		p := sub.Bounds().Min
+		sub.Set(p.X, p.Y, color.Gray{Y: 0})
+		assertEqual(t, parent.Threshold.dotColor(true), parent.At(p.X, p.Y), "Set on the sub image should update the parent.")
+	}
+	for _, r := range []image.Rectangle{
+		image.Rect(10, 20, 100, 80),
+		image.Rect(11, 21, 101, 83),
+		image.Rect(-10, -10, 5, 3),
+		image.Rect(240, 240, 300, 300),
+	} {
+		r := r
+		t.Run(r.String(), func(t *testing.T) { subImage(t, r) })
+	}
+
+	// Empty intersection.
+	empty := NewGray(image.Rect(0, 0, 4, 4)).SubImage(image.Rect(10, 10, 20, 20)).(*Gray)
+	assertEqual(t, true, empty.Bounds().Empty(), "Unexpected empty sub image bounds.")
+	assertEqual(t, "", encodeString(t, empty), "Unexpected encoded empty sub image.")
+}
+
 func assertEqual(tb testing.TB, expect, actual interface{}, msg string, args ...interface{}) bool {
 	tb.Helper()
 
@@ -0,0 +1,210 @@
+package bug
+
+import (
+	"image"
+)
+
+// Morphological operations, at dot resolution. The dots outside the image
+// are considered unset. The results are new images, the dots that changed
+// getting their canonical color while the others keep their grayscale.
+
+// Kernel is a structuring element, as the offsets of its dots
+// relative to its origin.
+type Kernel []image.Point
+
+// SquareKernel returns a (2*radius+1)x(2*radius+1) square structuring element.
+func SquareKernel(radius int) Kernel {
+	var k Kernel
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			k = append(k, image.Pt(x, y))
+		}
+	}
+	return k
+}
+
+// CrossKernel returns a cross shaped structuring element, with arms of the given length.
+func CrossKernel(radius int) Kernel {
+	k := Kernel{{}}
+	for i := 1; i <= radius; i++ {
+		k = append(k, image.Pt(-i, 0), image.Pt(i, 0), image.Pt(0, -i), image.Pt(0, i))
+	}
+	return k
+}
+
+// DiskKernel returns a disk shaped structuring element of the given radius.
+func DiskKernel(radius int) Kernel {
+	var k Kernel
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y <= radius*radius {
+				k = append(k, image.Pt(x, y))
+			}
+		}
+	}
+	return k
+}
+
+// dotGrid holds the dots of an image, one bool per pixel, row by row.
+type dotGrid struct {
+	dots []bool
+	rect image.Rectangle
+}
+
+// newDotGrid returns the dots of p.
+func newDotGrid(p *Gray) *dotGrid {
+	b := p.Bounds()
+	g := &dotGrid{dots: make([]bool, b.Dx()*b.Dy()), rect: b}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g.dots[g.offset(x, y)] = p.isSet(x, y)
+		}
+	}
+	return g
+}
+
+// offset returns the index of the given pixel.
+func (g *dotGrid) offset(x, y int) int {
+	return (y-g.rect.Min.Y)*g.rect.Dx() + x - g.rect.Min.X
+}
+
+// at returns the dot of the given pixel, false outside the grid.
+func (g *dotGrid) at(x, y int) bool {
+	return image.Pt(x, y).In(g.rect) && g.dots[g.offset(x, y)]
+}
+
+// dilate returns the grid dilated by k.
+func (g *dotGrid) dilate(k Kernel) *dotGrid {
+	out := &dotGrid{dots: make([]bool, len(g.dots)), rect: g.rect}
+	for y := g.rect.Min.Y; y < g.rect.Max.Y; y++ {
+		for x := g.rect.Min.X; x < g.rect.Max.X; x++ {
+			for _, o := range k {
+				if g.at(x-o.X, y-o.Y) {
+					out.dots[out.offset(x, y)] = true
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// erode returns the grid eroded by k.
+func (g *dotGrid) erode(k Kernel) *dotGrid {
+	out := &dotGrid{dots: make([]bool, len(g.dots)), rect: g.rect}
+	for y := g.rect.Min.Y; y < g.rect.Max.Y; y++ {
+		for x := g.rect.Min.X; x < g.rect.Max.X; x++ {
+			dot := true
+			for _, o := range k {
+				if !g.at(x+o.X, y+o.Y) {
+					dot = false
+					break
+				}
+			}
+			out.dots[out.offset(x, y)] = dot
+		}
+	}
+	return out
+}
+
+// withDots returns a copy of p with the dots of the grid.
+func (p *Gray) withDots(g *dotGrid) *Gray {
+	b := p.Bounds()
+	dst := newGray(b, p.Threshold)
+	dst.Dither, dst.Classifier = p.Dither, p.Classifier
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dot := g.dots[g.offset(x, y)]
+			c := p.Gray.Pix[p.Gray.PixOffset(x, y)]
+			if dot != p.isSet(x, y) {
+				c = p.Threshold.dotColor(dot).Y
+			}
+			dst.Gray.Pix[dst.Gray.PixOffset(x, y)] = c
+			dst.setDot(x, y, dot)
+		}
+	}
+	return dst
+}
+
+// Dilate returns a copy of the image with the dots grown by the structuring element k.
+func (p *Gray) Dilate(k Kernel) *Gray {
+	return p.withDots(newDotGrid(p).dilate(k))
+}
+
+// Erode returns a copy of the image with the dots shrunk by the structuring element k.
+func (p *Gray) Erode(k Kernel) *Gray {
+	return p.withDots(newDotGrid(p).erode(k))
+}
+
+// Open returns a copy of the image eroded then dilated by k,
+// removing the dots and details smaller than k.
+func (p *Gray) Open(k Kernel) *Gray {
+	return p.withDots(newDotGrid(p).erode(k).dilate(k))
+}
+
+// Close returns a copy of the image dilated then eroded by k,
+// filling the gaps and holes smaller than k.
+func (p *Gray) Close(k Kernel) *Gray {
+	return p.withDots(newDotGrid(p).dilate(k).erode(k))
+}
+
+// Outline returns a copy of the image only keeping the dots
+// with at least one unset horizontal or vertical neighbor.
+func (p *Gray) Outline() *Gray {
+	g := newDotGrid(p)
+	inner := g.erode(CrossKernel(1))
+	for i, dot := range inner.dots {
+		g.dots[i] = g.dots[i] && !dot
+	}
+	return p.withDots(g)
+}
+
+// Thin returns a copy of the image with the shapes reduced to 1 dot wide
+// skeletons, preserving their connectivity, using the Zhang-Suen algorithm.
+func (p *Gray) Thin() *Gray {
+	g := newDotGrid(p)
+	var remove []int
+	for changed := true; changed; {
+		changed = false
+		for pass := 0; pass < 2; pass++ {
+			remove = remove[:0]
+			for y := g.rect.Min.Y; y < g.rect.Max.Y; y++ {
+				for x := g.rect.Min.X; x < g.rect.Max.X; x++ {
+					if g.dots[g.offset(x, y)] && g.thinnable(x, y, pass) {
+						remove = append(remove, g.offset(x, y))
+					}
+				}
+			}
+			for _, i := range remove {
+				g.dots[i] = false
+			}
+			changed = changed || len(remove) > 0
+		}
+	}
+	return p.withDots(g)
+}
+
+// thinnable checks if the dot x,y can be removed by the given Zhang-Suen pass.
+func (g *dotGrid) thinnable(x, y, pass int) bool {
+	// Neighbors, clockwise from the top.
+	n := [8]bool{
+		g.at(x, y-1), g.at(x+1, y-1), g.at(x+1, y), g.at(x+1, y+1),
+		g.at(x, y+1), g.at(x-1, y+1), g.at(x-1, y), g.at(x-1, y-1),
+	}
+	count, transitions := 0, 0
+	for i, dot := range n {
+		if dot {
+			count++
+		}
+		if !dot && n[(i+1)%8] {
+			transitions++
+		}
+	}
+	if count < 2 || count > 6 || transitions != 1 {
+		return false
+	}
+	if pass == 0 {
+		return !(n[0] && n[2] && n[4]) && !(n[2] && n[4] && n[6])
+	}
+	return !(n[0] && n[2] && n[6]) && !(n[0] && n[4] && n[6])
+}
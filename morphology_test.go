@@ -0,0 +1,134 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// newPatternImage returns an image from a pattern of '#' for the dots and '.' otherwise.
+func newPatternImage(pattern string) *Gray {
+	lines := strings.Split(strings.TrimSpace(pattern), "\n")
+	img := NewGray(image.Rect(0, 0, len(strings.TrimSpace(lines[0])), len(lines)))
+	for y, line := range lines {
+		for x, c := range strings.TrimSpace(line) {
+			if c == '#' {
+				img.Set(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+	return img
+}
+
+// dotPattern returns the pattern of the image's dots, see newPatternImage.
+func dotPattern(img *Gray) string {
+	var buf strings.Builder
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.isSet(x, y) {
+				buf.WriteByte('#')
+			} else {
+				buf.WriteByte('.')
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+func TestKernels(t *testing.T) {
+	assertEqual(t, 9, len(SquareKernel(1)), "Unexpected square kernel.")
+	assertEqual(t, 9, len(CrossKernel(2)), "Unexpected cross kernel.")
+	assertEqual(t, 13, len(DiskKernel(2)), "Unexpected disk kernel.")
+}
+
+func TestMorphology(t *testing.T) {
+	img := newPatternImage(`
+		........
+		.####...
+		.####..#
+		.####...
+		........
+		...#.#..
+		...###..
+		........
+	`)
+	img.Set(2, 2, color.Gray{Y: 0x10}) // Retained grayscale.
+
+	for _, tt := range []struct {
+		name      string
+		transform func(*Gray) *Gray
+		expect    string
+	}{
+		{"dilate", func(p *Gray) *Gray { return p.Dilate(CrossKernel(1)) }, `
+			.####...
+			######.#
+			########
+			######.#
+			.#####..
+			..#####.
+			..#####.
+			...###..
+		`},
+		{"erode", func(p *Gray) *Gray { return p.Erode(SquareKernel(1)) }, `
+			........
+			........
+			..##....
+			........
+			........
+			........
+			........
+			........
+		`},
+		{"open", func(p *Gray) *Gray { return p.Open(SquareKernel(1)) }, `
+			........
+			.####...
+			.####...
+			.####...
+			........
+			........
+			........
+			........
+		`},
+		{"close", func(p *Gray) *Gray { return p.Close(SquareKernel(1)) }, `
+			........
+			.####...
+			.######.
+			.#####..
+			...###..
+			...###..
+			...###..
+			........
+		`},
+		{"outline", (*Gray).Outline, `
+			........
+			.####...
+			.#..#..#
+			.####...
+			........
+			...#.#..
+			...###..
+			........
+		`},
+		{"thin", (*Gray).Thin, `
+			........
+			........
+			..#....#
+			........
+			........
+			........
+			...###..
+			........
+		`},
+	} {
+		out := tt.transform(img)
+		assertEqual(t, newPatternImage(tt.expect).Bounds(), out.Bounds(), "Unexpected %s bounds.", tt.name)
+		assertEqual(t, dotPattern(newPatternImage(tt.expect)), dotPattern(out), "Unexpected %s.", tt.name)
+		assertAgree(t, out, "Gray after %s.", tt.name)
+		if out.isSet(2, 2) {
+			assertEqual(t, color.Gray{Y: 0x10}, out.GrayAt(2, 2), "Unchanged dot should keep its grayscale after %s.", tt.name)
+		}
+	}
+}
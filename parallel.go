@@ -0,0 +1,55 @@
+package bug
+
+import "sync"
+
+// minBandRows is the minimum number of cell rows per band,
+// so small images don't pay for the goroutines.
+const minBandRows = 16
+
+// band is a range of cell rows, Max excluded.
+type band struct {
+	Min, Max int
+}
+
+// splitBands splits the cell rows min to max in bands, one per worker.
+// A workers count <= 1 means a single band, i.e. serial processing.
+func splitBands(min, max, workers int) []band {
+	if n := (max - min) / minBandRows; workers > n {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	bands := make([]band, 0, workers)
+	for i := 0; i < workers; i++ {
+		// Spread the remainder over the first bands.
+		bands = append(bands, band{
+			Min: min + (max-min)*i/workers,
+			Max: min + (max-min)*(i+1)/workers,
+		})
+	}
+	return bands
+}
+
+// parallelBands calls fct for each band of the cell rows min to max,
+// concurrently when there is more than one, and waits for them to be done.
+// As thresholding and ordered dithering only depend on the pixel itself,
+// bands of cell rows can be processed independently.
+func parallelBands(min, max, workers int, fct func(b band)) {
+	bands := splitBands(min, max, workers)
+	if len(bands) == 1 {
+		fct(bands[0])
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(bands))
+	for _, b := range bands {
+		go func(b band) {
+			defer wg.Done()
+			fct(b)
+		}(b)
+	}
+	wg.Wait()
+}
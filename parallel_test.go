@@ -0,0 +1,110 @@
+package bug
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// newNoiseImage creates a large pseudo random image of the given type.
+func newNoiseImage(newImage func(image.Rectangle) image.Image, r image.Rectangle) image.Image {
+	img := newImage(r).(interface {
+		image.Image
+		Set(x, y int, c color.Color)
+	})
+	rnd := rand.New(rand.NewSource(42))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x + y), G: uint8(rnd.Intn(256)), B: uint8(y), A: uint8(0xff - rnd.Intn(16))})
+		}
+	}
+	return img
+}
+
+// encodeWorkers encodes the given image with the given number of workers.
+func encodeWorkers(tb testing.TB, img image.Image, t Threshold, workers int) []byte {
+	tb.Helper()
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf).WithWorkers(workers)
+	enc.Threshold = t
+	requireNoError(tb, enc.Encode(img), "Encode image with %d workers.", workers)
+	return buf.Bytes()
+}
+
+// Make sure the parallel conversion and encoding yield the same result as the serial ones.
+func TestParallel(t *testing.T) {
+	img, _, err := image.Decode(mustGetFile(t, "testdata/biplane.png"))
+	requireNoError(t, err, "Decode testdata image.")
+
+	r := image.Rect(-3, -7, 309, 601)
+	sources := map[string]image.Image{
+		"biplane": img,
+		"gray":    newNoiseImage(func(r image.Rectangle) image.Image { return image.NewGray(r) }, r),
+		"rgba":    newNoiseImage(func(r image.Rectangle) image.Image { return image.NewRGBA(r) }, r),
+		"nrgba":   newNoiseImage(func(r image.Rectangle) image.Image { return image.NewNRGBA(r) }, r),
+	}
+	for name, src := range sources {
+		for _, o := range []Options{
+			{Threshold: DefaultThreshold},
+			{Threshold: DefaultThreshold.Inverse()},
+			{Threshold: 180, Dither: OrderedDither},
+		} {
+			o.Workers = 1
+			expect := ConvertWithOptions(src, &o)
+			expectEncoded := encodeWorkers(t, expect, o.Threshold, 1)
+			if o.Dither == NoDither && !bytes.Equal(expectEncoded, encodeWorkers(t, src, o.Threshold, 1)) {
+				t.Fatalf("Unexpected serial conversion and encoding of %s.", name)
+			}
+
+			for _, workers := range []int{0, 2, 7} {
+				o.Workers = workers
+				actual := ConvertWithOptions(src, &o)
+				if !bytes.Equal(expect.Gray.Pix, actual.Gray.Pix) {
+					t.Fatalf("Unexpected parallel conversion pixels of %s with %d workers.", name, workers)
+				}
+				if !bytes.Equal(expectEncoded, encodeWorkers(t, actual, o.Threshold, workers)) {
+					t.Fatalf("Unexpected parallel encoding of %s with %d workers.", name, workers)
+				}
+				if o.Dither == NoDither && !bytes.Equal(expectEncoded, encodeWorkers(t, src, o.Threshold, workers)) {
+					t.Fatalf("Unexpected parallel conversion and encoding of %s with %d workers.", name, workers)
+				}
+
+				// Re-thresholding BUG images.
+				g := ConvertWithOptions(src, &Options{Threshold: DefaultThreshold, Workers: 1})
+				if !bytes.Equal(expectEncoded, encodeWorkers(t, ConvertWithOptions(g, &o), o.Threshold, 1)) {
+					t.Fatalf("Unexpected parallel re-threshold of %s with %d workers.", name, workers)
+				}
+			}
+		}
+	}
+}
+
+// Test the bands split.
+func TestSplitBands(t *testing.T) {
+	assertEqual(t, []band{{0, 10}}, splitBands(0, 10, 4), "Small images should not be split.")
+	assertEqual(t, []band{{-5, 11}, {11, 27}, {27, 44}}, splitBands(-5, 44, 3), "Unexpected bands.")
+	assertEqual(t, []band{{3, 3}}, splitBands(3, 3, 0), "Unexpected empty bands.")
+	assertEqual(t, []band{{0, 100}}, splitBands(0, 100, 0), "0 workers should be serial.")
+}
+
+func benchmarkConvert(b *testing.B, workers int) {
+	src := newNoiseImage(func(r image.Rectangle) image.Image { return image.NewRGBA(r) }, image.Rect(0, 0, 2000, 1500))
+	o := &Options{Threshold: DefaultThreshold, Workers: workers}
+	var buf bytes.Buffer
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := NewEncoder(&buf).WithWorkers(workers).Encode(ConvertWithOptions(src, o)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvertSerial(b *testing.B)   { benchmarkConvert(b, 1) }
+func BenchmarkConvertParallel(b *testing.B) { benchmarkConvert(b, runtime.GOMAXPROCS(0)) }
@@ -0,0 +1,193 @@
+package bug
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// PC Screen Font magic numbers.
+var (
+	psf1Magic = []byte{0x36, 0x04}
+	psf2Magic = []byte{0x72, 0xb5, 0x4a, 0x86}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// PSF1 modes and PSF2 flags.
+const (
+	psf1Mode512    = 0x01
+	psf1ModeHasTab = 0x06
+	psf2HasTable   = 0x01
+
+	// Unicode table markers, ending the runes of a glyph and starting its
+	// sequences. Single bytes 0xff and 0xfe in PSF2.
+	psfSeparator = 0xffff
+	psfStartSeq  = 0xfffe
+)
+
+// maxPSFSize is the maximum size of the PSF fonts, once gunzipped, in bytes.
+// The largest console fonts are a few dozens of kilobytes.
+const maxPSFSize = 4 << 20
+
+// psf2Header is the header of the PSF2 fonts, in little endian.
+type psf2Header struct {
+	Magic      [4]byte
+	Version    uint32
+	HeaderSize uint32
+	Flags      uint32
+	Length     uint32 // Number of glyphs.
+	CharSize   uint32 // Bytes per glyph.
+	Height     uint32
+	Width      uint32
+}
+
+// ParsePSF parses a PC Screen Font, version 1 or 2, as used by the Linux
+// console, gzipped or not (.psf.gz). The glyphs are looked up using the
+// font's Unicode table, or by their index without it. All the glyphs advance
+// by the font's width, and the baseline sits below the 'H' glyph.
+// The glyphs missing from the font fall back to Font5x7.
+func ParsePSF(r io.Reader) (*Font, error) {
+	buf, err := ioutil.ReadAll(io.LimitReader(r, maxPSFSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read PSF font: %w", err)
+	}
+	if bytes.HasPrefix(buf, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(buf))
+		if err != nil {
+			return nil, fmt.Errorf("%w: PSF gzip: %s", ErrInvalidFont, err)
+		}
+		if buf, err = ioutil.ReadAll(io.LimitReader(zr, maxPSFSize+1)); err != nil {
+			return nil, fmt.Errorf("%w: PSF gzip: %s", ErrInvalidFont, err)
+		}
+	}
+	if len(buf) > maxPSFSize {
+		return nil, fmt.Errorf("%w: PSF font larger than %d bytes", ErrInvalidFont, maxPSFSize)
+	}
+
+	var (
+		w, h, n, charSize int
+		data, table       []byte
+		hasTable, utf     bool
+	)
+	switch {
+	case bytes.HasPrefix(buf, psf1Magic) && len(buf) >= 4:
+		mode := buf[2]
+		w, h, n, charSize = 8, int(buf[3]), 256, int(buf[3])
+		if mode&psf1Mode512 != 0 {
+			n = 512
+		}
+		data, hasTable = buf[4:], mode&psf1ModeHasTab != 0
+	case bytes.HasPrefix(buf, psf2Magic):
+		var hdr psf2Header
+		if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr); err != nil {
+			return nil, fmt.Errorf("%w: PSF2 header: %s", ErrInvalidFont, err)
+		}
+		w, h, n, charSize = int(hdr.Width), int(hdr.Height), int(hdr.Length), int(hdr.CharSize)
+		if hdr.HeaderSize < uint32(binary.Size(hdr)) || int(hdr.HeaderSize) > len(buf) ||
+			!validGlyphSize(w, h) || charSize != h*((w+7)/8) {
+			return nil, fmt.Errorf("%w: invalid PSF2 header", ErrInvalidFont)
+		}
+		data, hasTable, utf = buf[hdr.HeaderSize:], hdr.Flags&psf2HasTable != 0, true
+	default:
+		return nil, fmt.Errorf("%w: not a PSF font", ErrInvalidFont)
+	}
+	if w <= 0 || h <= 0 || n <= 0 || len(data)/charSize < n {
+		return nil, fmt.Errorf("%w: truncated PSF glyphs", ErrInvalidFont)
+	}
+	data, table = data[:n*charSize], data[n*charSize:]
+
+	glyphs := make([]*Glyph, n)
+	for i := range glyphs {
+		glyphs[i] = newPSFGlyph(data[i*charSize:(i+1)*charSize], w, h)
+	}
+
+	// The Unicode table lists the runes of each glyph, skipping the sequences.
+	f := newFont(h, h)
+	add := func(i int, r rune) {
+		if _, ok := f.glyphs[r]; !ok {
+			f.glyphs[r] = glyphs[i]
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !hasTable {
+			add(i, rune(i))
+			continue
+		}
+		for seq := false; ; {
+			var r rune
+			if utf {
+				if len(table) == 0 {
+					return nil, fmt.Errorf("%w: truncated PSF2 Unicode table", ErrInvalidFont)
+				}
+				size := 1
+				switch table[0] {
+				case 0xff:
+					r = psfSeparator
+				case 0xfe:
+					r = psfStartSeq
+				default:
+					r, size = utf8.DecodeRune(table)
+				}
+				table = table[size:]
+			} else {
+				if len(table) < 2 {
+					return nil, fmt.Errorf("%w: truncated PSF1 Unicode table", ErrInvalidFont)
+				}
+				r, table = rune(binary.LittleEndian.Uint16(table)), table[2:]
+			}
+			if r == psfSeparator {
+				break
+			}
+			if seq = seq || r == psfStartSeq; !seq {
+				add(i, r)
+			}
+		}
+	}
+
+	// Guess the baseline from the bottom of the capital letters or digits.
+	for _, r := range []rune{'H', 'X', '0'} {
+		if g, ok := f.glyphs[r]; ok {
+			if ascent := glyphBottom(g.Mask); ascent > 0 {
+				f.Ascent = ascent
+				break
+			}
+		}
+	}
+	for _, g := range glyphs {
+		g.Mask.Rect = g.Mask.Rect.Sub(image.Pt(0, f.Ascent))
+	}
+	f.Fallback = Font5x7
+	return f, nil
+}
+
+// newPSFGlyph returns the w x h glyph of the given rows, the top-left at the
+// origin, each row being padded to a whole number of bytes.
+func newPSFGlyph(rows []byte, w, h int) *Glyph {
+	g := &Glyph{Mask: image.NewAlpha(image.Rect(0, 0, w, h)), Advance: w}
+	stride := (w + 7) / 8
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if rows[y*stride+x/8]&(0x80>>uint(x%8)) != 0 {
+				g.Mask.Pix[g.Mask.PixOffset(x, y)] = 0xff
+			}
+		}
+	}
+	return g
+}
+
+// glyphBottom returns the row below the lowest dot of the mask, or 0 if empty.
+func glyphBottom(m *image.Alpha) int {
+	for y := m.Rect.Max.Y - 1; y >= m.Rect.Min.Y; y-- {
+		for x := m.Rect.Min.X; x < m.Rect.Max.X; x++ {
+			if m.Pix[m.PixOffset(x, y)] != 0 {
+				return y + 1
+			}
+		}
+	}
+	return 0
+}
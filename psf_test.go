@@ -0,0 +1,101 @@
+package bug
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestParsePSF(t *testing.T) {
+	// PSF2 with a Unicode table.
+	f, err := ParsePSF(mustGetFile(t, "testdata/font.psf"))
+	requireNoError(t, err, "Parse PSF2 font.")
+	assertEqual(t, 8, f.Height, "Unexpected PSF2 height.")
+	assertEqual(t, 7, f.Ascent, "Unexpected PSF2 ascent, from the 'H' glyph.")
+	assertEqual(t, Font5x7, f.Fallback, "Unexpected PSF2 fallback.")
+	for _, r := range []rune{'?', 'A', 'Α', 'Ω', 'H'} {
+		g := f.glyphs[r]
+		if g == nil {
+			t.Fatalf("Missing PSF2 glyph for %q.", r)
+		}
+		assertEqual(t, image.Rect(0, -7, 6, 1), g.Mask.Rect, "Unexpected %q glyph bounds.", r)
+		assertEqual(t, 6, g.Advance, "Unexpected %q glyph advance.", r)
+	}
+	// Several runes for a glyph, but no sequences.
+	assertEqual(t, f.glyphs['A'], f.glyphs['Α'], "Unexpected glyph for the Greek alpha.")
+	if _, ok := f.glyphs['O']; ok {
+		t.Fatal("Unexpected glyph for a sequence.")
+	}
+	// Missing glyphs use the font's '?' one.
+	assertEqual(t, f.glyphs['?'], f.Glyph('Ó'), "Unexpected missing glyph.")
+
+	img := NewText("HΩ", &TextOptions{Font: f})
+	assertEqual(t, dotPattern(newPatternImage(`
+		............
+		.#..#..####.
+		.#..#.#....#
+		.####.#....#
+		.#..#.#....#
+		.#..#..#..#.
+		.#..#.##..##
+		............
+	`)), dotPattern(img), "Unexpected PSF2 text.")
+	assertAgree(t, img, "Gray after PSF2 text.")
+
+	// Gzipped PSF1 without table, the glyphs matching their index.
+	f, err = ParsePSF(mustGetFile(t, "testdata/font.psf.gz"))
+	requireNoError(t, err, "Parse gzipped PSF1 font.")
+	assertEqual(t, 8, f.Height, "Unexpected PSF1 height.")
+	assertEqual(t, 7, f.Ascent, "Unexpected PSF1 ascent, from the 'H' glyph.")
+	assertEqual(t, 256, len(f.glyphs), "Unexpected PSF1 glyph count.")
+	assertEqual(t, dotPattern(newPatternImage(`
+		#....#....#.....
+		#....#....#.....
+		#....#....#.....
+		######....#.....
+		#....#....#.....
+		#....#..........
+		#....#....#.....
+		................
+	`)), dotPattern(NewText("H!", &TextOptions{Font: f})), "Unexpected PSF1 text.")
+}
+
+func TestParsePSFErrors(t *testing.T) {
+	psf2 := mustGetFile(t, "testdata/font.psf").Bytes()
+	header := func(offset int, value byte) []byte {
+		buf := append([]byte(nil), psf2...)
+		buf[offset] = value
+		return buf
+	}
+	// Gzip bomb.
+	bomb := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(bomb)
+	_, err := zw.Write(append(append([]byte(nil), psf2...), make([]byte, maxPSFSize)...))
+	requireNoError(t, err, "Write gzipped font.")
+	requireNoError(t, zw.Close(), "Close gzipped font.")
+
+	for _, tt := range []struct {
+		name string
+		font []byte
+	}{
+		{"empty", nil},
+		{"not psf", []byte("STARTFONT 2.1\n")},
+		{"invalid gzip", []byte{0x1f, 0x8b, 0x08}},
+		{"psf1 truncated glyphs", []byte{0x36, 0x04, 0x00, 0x08, 0xff}},
+		{"psf1 empty glyphs", []byte{0x36, 0x04, 0x00, 0x00}},
+		{"psf2 truncated header", psf2[:20]},
+		{"psf2 header size", header(8, 64)},
+		{"psf2 char size", header(20, 7)},
+		{"psf2 huge glyphs", header(30, 1)},
+		{"gzip bomb", bomb.Bytes()},
+		{"psf2 truncated glyphs", psf2[:40]},
+		{"psf2 truncated table", psf2[:len(psf2)-1]},
+		{"psf1 truncated table", append([]byte{0x36, 0x04, 0x02, 0x01}, make([]byte, 257)...)},
+	} {
+		if _, err := ParsePSF(bytes.NewReader(tt.font)); !errors.Is(err, ErrInvalidFont) {
+			t.Fatalf("Expected an invalid font error for %s, got %v.", tt.name, err)
+		}
+	}
+}
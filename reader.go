@@ -4,27 +4,81 @@ import (
 	"bytes"
 	"errors"
 	"image"
-	"image/color"
 	"io"
 	"io/ioutil"
+	"regexp"
+	"unicode"
 	"unicode/utf8"
 )
 
 func init() {
 	image.RegisterFormat("bug", string(rune(brailleCharOffset)), Decode, DecodeConfig)
 	image.RegisterFormat("bug", string(rune(0x283f)), Decode, DecodeConfig)
+	image.RegisterFormat("bug", binaryMagic, Decode, DecodeConfig)
 }
 
 // Decode creates a new BUG image from the given stream.
+// If the stream holds an animation, only the first frame is returned.
 func Decode(r io.Reader) (image.Image, error) {
 	return NewDecoder(r).Decode()
 }
 
+// DecodeMode controls how strictly the decoder validates its input.
+type DecodeMode int
+
+// Available decode modes.
+const (
+	// DecodeDefault accepts any rune and rows shorter than the first one.
+	DecodeDefault DecodeMode = iota
+	// DecodeStrict rejects runes outside of U+2800 - U+28FF and ragged rows.
+	DecodeStrict
+	// DecodeLenient normalizes braille art pasted from the wild:
+	// UTF-8 BOM, CRLF line endings, trailing whitespace, ANSI color codes,
+	// ASCII spaces used as empty cells and ragged rows. Other runes
+	// are considered empty cells, as well as the lines starting with
+	// the directive prefix without being a valid directive.
+	DecodeLenient
+)
+
+// utf8BOM is the UTF-8 byte order mark some editors prepend.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// sgrSequence matches the ANSI Select Graphic Rendition escape sequences (i.e. colors).
+var sgrSequence = regexp.MustCompile(`\x1b\[[0-9;:]*m`)
+
+// normalize cleans up the given braille art for the lenient mode.
+func normalize(buf []byte) []byte {
+	buf = bytes.TrimPrefix(buf, utf8BOM)
+	buf = sgrSequence.ReplaceAll(buf, nil)
+	buf = bytes.TrimRightFunc(buf, unicode.IsSpace)
+
+	empty := []byte(string(brailleCharOffset))
+	lines := bytes.Split(buf, []byte{'\n'})
+	for i, line := range lines {
+		// Also removes the \r from CRLF line endings.
+		trimmed := bytes.TrimRightFunc(line, unicode.IsSpace)
+		switch {
+		case len(trimmed) == 0 && bytes.IndexByte(line, ' ') >= 0:
+			// A row of spaces is a blank row, not an empty line to trim.
+			trimmed = empty
+		case !isKnownDirective(trimmed):
+			trimmed = bytes.Replace(trimmed, []byte{' '}, empty, -1)
+		}
+		lines[i] = trimmed
+	}
+	return bytes.Join(lines, []byte{'\n'})
+}
+
 // Decoder handles the BUG decoding.
 type Decoder struct {
 	r io.Reader
 
 	Threshold
+	Mode DecodeMode
+	// Dots is the number of dots per cell of the text variant: 8 by default,
+	// or 6 for the output of the 6-dot encoder, each cell being 2x3 pixels.
+	// The dots 7 and 8 are then ignored, or rejected by DecodeStrict.
+	Dots int
 }
 
 func NewDecoder(r io.Reader) *Decoder {
@@ -36,69 +90,180 @@ func (d *Decoder) WithThreshold(t Threshold) *Decoder {
 	return d
 }
 
+// WithMode sets the decode mode.
+func (d *Decoder) WithMode(m DecodeMode) *Decoder {
+	d.Mode = m
+	return d
+}
+
+// WithDots sets the number of dots per cell, 8 or 6.
+func (d *Decoder) WithDots(n int) *Decoder {
+	d.Dots = n
+	return d
+}
+
 func (d *Decoder) Decode() (image.Image, error) {
+	anim, err := d.decode(true)
+	if err != nil {
+		return nil, err
+	}
+	return anim.Image[0], nil
+}
+
+// decode consumes the stream and decodes the frames it holds.
+// When firstOnly is set, decoding stops after the first frame.
+func (d *Decoder) decode(firstOnly bool) (*Animation, error) {
 	// Consume the stream.
 	buf, err := ioutil.ReadAll(d.r)
 	if err != nil {
 		return nil, err
 	}
 
-	// Split the lines.
-	rows := bytes.Split(bytes.TrimSpace(buf), []byte{'\n'})
+	if bytes.HasPrefix(buf, []byte(binaryMagic)) {
+		return d.decodeBinary(buf, firstOnly)
+	}
+	return d.decodeText(buf, firstOnly)
+}
+
+// decodeText decodes the text variant of the format.
+func (d *Decoder) decodeText(buf []byte, firstOnly bool) (*Animation, error) {
+	if d.Mode == DecodeLenient {
+		buf = normalize(buf)
+	}
+
+	// Split the lines, keeping track of the skipped ones for error reporting.
+	trimmed := bytes.TrimSpace(buf)
+	if len(trimmed) == 0 {
+		return nil, ErrEmpty
+	}
+	lineOffset := 1 + bytes.Count(buf[:bytes.Index(buf, trimmed)], []byte{'\n'})
+	rows := bytes.Split(trimmed, []byte{'\n'})
+
+	anim := &Animation{}
+	start := 0
+	for i, line := range rows {
+		// Lenient decoding only recognizes the well formed directives,
+		// other lines being regular rows, e.g. pasted text.
+		if !isDirective(line) || (d.Mode == DecodeLenient && !isKnownDirective(line)) {
+			continue
+		}
+		key, val, err := parseDirective(line)
+		if err != nil {
+			return nil, &FormatError{Line: lineOffset + i, Err: err}
+		}
+		if key == loopDirective {
+			anim.LoopCount = val
+			start = i + 1
+			continue
+		}
+		img, err := d.decodeRows(rows[start:i], lineOffset+start)
+		if err != nil {
+			return nil, err
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, val)
+		if firstOnly {
+			return anim, nil
+		}
+		start = i + 1
+	}
+
+	// Regular images don't have a frame delimiter, the whole content is the frame.
+	if len(anim.Image) == 0 || start < len(rows) {
+		img, err := d.decodeRows(rows[start:], lineOffset+start)
+		if err != nil {
+			return nil, err
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, 0)
+	}
+	return anim, nil
+}
+
+// decodeRows creates a new BUG image from the given lines of braille runes.
+// firstLine is the line number of the first row in the stream.
+func (d *Decoder) decodeRows(rows [][]byte, firstLine int) (*Gray, error) {
 	if len(rows) == 0 {
-		return nil, errors.New("empty BUG image")
+		return nil, ErrEmpty
 	}
 
 	// Count the width/height.
 	width := utf8.RuneCount(rows[0])
 	height := len(rows)
+	if d.Mode == DecodeLenient {
+		// Short rows get padded with empty cells.
+		for _, line := range rows[1:] {
+			if n := utf8.RuneCount(line); n > width {
+				width = n
+			}
+		}
+	}
+	if width == 0 {
+		return nil, ErrEmpty
+	}
 
 	// Create the new BUG image object.
-	img := NewGray(image.Rectangle{
+	img := newGray(image.Rectangle{
 		Max: image.Point{
 			X: width * 2,  // 2 cols per cell.
 			Y: height * 4, // 4 rows per cell.
 		},
-	})
-	img.Threshold = d.Threshold
-
-	if len(rows) > len(img.content) {
-		return nil, errors.New("invalid BUG image: corrupted column")
+	}, d.Threshold)
+	if d.Dots == 6 {
+		img = newSixDotGray(width, height, d.Threshold)
 	}
 
 	// Row by row.
 	for row, line := range rows {
 		cells := bytes.Runes(line)
-		if len(cells) > len(img.content[row]) {
-			return nil, errors.New("invalid BUG image: corrupted row")
+		if len(cells) > width || (d.Mode == DecodeStrict && len(cells) != width) {
+			formatErr := &FormatError{Line: firstLine + row, Column: len(cells) + 1, Err: ErrRaggedRow}
+			if len(cells) > width {
+				formatErr.Column, formatErr.Rune = width+1, cells[width]
+			}
+			return nil, formatErr
 		}
 		// For each cell.
 		for col, cell := range cells {
+			if d.Mode == DecodeStrict && (!isBraille(cell) || (d.Dots == 6 && cell > brailleCharOffset+sixDotMask)) {
+				return nil, &FormatError{Line: firstLine + row, Column: col + 1, Rune: cell, Err: ErrInvalidRune}
+			}
+			if d.Mode == DecodeLenient && !isBraille(cell) {
+				cell = brailleCharOffset
+			}
 			// Remove the braillCharOffset to get the actual value.
-			cellVal := uint8(cell - brailleCharOffset)
-
-			// Store the value in the image object.
-			img.content[row][col] = cellVal
-
-			// Update the "real" image for each of the 8 pixel in the cell.
-			x, y := col*2, row*4 // Pixel origin of the cell.
-			for i := 0; i < 2; i++ {
-				for j := 0; j < 4; j++ {
-					// If set, use Opaque.
-					if cellVal&unicodeOffset(x+i, y+j) == cellVal {
-						img.Gray.Set(x+i, y+j, color.Opaque)
-					} else {
-						// Otherwise, transparent.
-						img.Gray.Set(x+i, y+j, color.Transparent)
-					}
-				}
+			if d.Dots == 6 {
+				img.setSixDotCell(col, row, uint8(cell-brailleCharOffset))
+				continue
 			}
+			img.setCellValue(col, row, uint8(cell-brailleCharOffset))
 		}
 	}
 
 	return img, nil
 }
 
+// isBraille checks if the given rune is a braille cell.
+func isBraille(r rune) bool {
+	return r >= brailleCharOffset && r <= brailleCharOffset+0xff
+}
+
+// setCellValue stores the given cell value and updates the "real" image
+// for each of the 8 pixels in the cell.
+func (p *Gray) setCellValue(col, row int, cellVal uint8) {
+	// Store the value in the image object.
+	p.content[row-p.Rect.Min.Y][col-p.Rect.Min.X] = cellVal
+
+	x, y := col*2, row*4 // Pixel origin of the cell.
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 4; j++ {
+			// Use the canonical color of the point state so the
+			// "real" image converts back to the same cell.
+			p.Gray.SetGray(x+i, y+j, p.Threshold.dotColor(cellVal&unicodeOffset(x+i, y+j) != 0))
+		}
+	}
+}
+
 // DecodeConfig complies with image.RegisterFormat but is not used.
 func DecodeConfig(r io.Reader) (image.Config, error) {
 	return image.Config{}, errors.New("decodeConfig for BUG requires a full read, use image.Decode instead")
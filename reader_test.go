@@ -0,0 +1,105 @@
+package bug
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test the strict decode mode errors.
+func TestDecodeStrict(t *testing.T) {
+	decodeError := func(t *testing.T, input string, expect error, line, column int, r rune) {
+		t.Helper()
+
+		_, err := NewDecoder(strings.NewReader(input)).WithMode(DecodeStrict).Decode()
+		if !errors.Is(err, expect) {
+			t.Fatalf("Unexpected error.\nExpect:\t%v\nActual:\t%v", expect, err)
+		}
+		var formatErr *FormatError
+		if !errors.As(err, &formatErr) {
+			t.Fatalf("Expected a FormatError, got %T: %v.", err, err)
+		}
+		assertEqual(t, line, formatErr.Line, "Unexpected error line.")
+		assertEqual(t, column, formatErr.Column, "Unexpected error column.")
+		assertEqual(t, r, formatErr.Rune, "Unexpected error rune.")
+	}
+
+	t.Run("ascii", func(t *testing.T) { decodeError(t, "⠀⠀\n⠀A\n", ErrInvalidRune, 2, 2, 'A') })
+	t.Run("emoji", func(t *testing.T) { decodeError(t, "\n\n⣿😀⣿\n", ErrInvalidRune, 3, 2, '😀') })
+	t.Run("invalid-utf8", func(t *testing.T) { decodeError(t, "⠀\xff", ErrInvalidRune, 1, 2, '�') })
+	t.Run("short-row", func(t *testing.T) { decodeError(t, "⠀⠀⠀\n⠀\n", ErrRaggedRow, 2, 2, 0) })
+	t.Run("long-row", func(t *testing.T) { decodeError(t, "⠀⠀\n⠀⠀⣿\n", ErrRaggedRow, 2, 3, '⣿') })
+	t.Run("directive", func(t *testing.T) { decodeError(t, "⠀⠀\n;foo=1\n", ErrDirective, 2, 0, 0) })
+}
+
+// Make sure the default mode remains permissive.
+func TestDecodeDefault(t *testing.T) {
+	img, err := Decode(strings.NewReader("⠀⣿\nA\n"))
+	requireNoError(t, err, "Decode non-strict image.")
+	assertEqual(t, "(0,0)-(4,8)", img.Bounds(), "Unexpected bounds.")
+
+	_, err = Decode(strings.NewReader("⠀\n⠀⣿\n"))
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) || !errors.Is(err, ErrRaggedRow) {
+		t.Fatalf("Expected ragged row FormatError, got %v.", err)
+	}
+	assertEqual(t, "invalid BUG image: line 2, column 2: ragged row '⣿' (U+28FF)", err, "Unexpected error message.")
+
+	// The missing cells of short rows are empty, whatever the threshold.
+	for _, threshold := range []Threshold{DefaultThreshold, -128} {
+		img, err := NewDecoder(strings.NewReader("⣿⣿\n⣿\n")).WithThreshold(threshold).Decode()
+		requireNoError(t, err, "Decode short row with threshold %d.", threshold)
+		assertAgree(t, img.(*Gray), "Gray after decoding a short row with threshold %d.", threshold)
+		img.(*Gray).Rethreshold()
+		assertEqual(t, "⣿⣿\n⣿⠀\n", encodeString(t, img), "Unexpected short row after re-thresholding with threshold %d.", threshold)
+	}
+}
+
+// Test empty images.
+func TestDecodeEmpty(t *testing.T) {
+	for _, mode := range []DecodeMode{DecodeDefault, DecodeStrict, DecodeLenient} {
+		for _, input := range []string{"", "\n \n", "\r\n"} {
+			_, err := NewDecoder(strings.NewReader(input)).WithMode(mode).Decode()
+			if !errors.Is(err, ErrEmpty) {
+				t.Errorf("Unexpected error for %q in mode %d: %v.", input, mode, err)
+			}
+		}
+	}
+}
+
+// Test decoding real-world pasted braille art.
+func TestDecodeLenient(t *testing.T) {
+	decodeLenient := func(t *testing.T, input, expect string) {
+		t.Helper()
+
+		img, err := NewDecoder(strings.NewReader(input)).WithMode(DecodeLenient).Decode()
+		requireNoError(t, err, "Decode lenient image.")
+		assertEqual(t, expect, encodeString(t, img), "Unexpected re-encoded image.")
+	}
+
+	t.Run("crlf", func(t *testing.T) { decodeLenient(t, "⣿⠀\r\n⠀⣿\r\n", "⣿⠀\n⠀⣿\n") })
+	t.Run("bom", func(t *testing.T) { decodeLenient(t, "\xef\xbb\xbf⣿⠀\n⠀⣿", "⣿⠀\n⠀⣿\n") })
+	t.Run("trailing-whitespace", func(t *testing.T) { decodeLenient(t, "⣿⠀ \t\n⠀⣿  \n\n", "⣿⠀\n⠀⣿\n") })
+	t.Run("spaces", func(t *testing.T) { decodeLenient(t, "  ⣿\n⣿ ⣿\n", "⠀⠀⣿\n⣿⠀⣿\n") })
+	t.Run("ragged", func(t *testing.T) {
+		decodeLenient(t, "⣿\n⣿⣿⣿\n\n⣿⣿\n", "⣿⠀⠀\n⣿⣿⣿\n⠀⠀⠀\n⣿⣿⠀\n")
+	})
+	t.Run("sgr", func(t *testing.T) {
+		decodeLenient(t, "\x1b[31m⣿\x1b[0m⠀\n\x1b[1;38;5;208m⠀⣿\x1b[m", "⣿⠀\n⠀⣿\n")
+	})
+	t.Run("invalid-runes", func(t *testing.T) { decodeLenient(t, "⣿A\n😀⣿", "⣿⠀\n⠀⣿\n") })
+	t.Run("blank-rows", func(t *testing.T) { decodeLenient(t, "  \n \r\n⣿⣿\n  \n", "⠀⠀\n⠀⠀\n⣿⣿\n") })
+	t.Run("text", func(t *testing.T) { decodeLenient(t, ";hi\n⣿", "⠀⠀⠀\n⣿⠀⠀\n") })
+	t.Run("directives", func(t *testing.T) {
+		anim, err := NewDecoder(strings.NewReader(";hi\n⣿\n;delay=5\n⣿\n")).WithMode(DecodeLenient).DecodeAll()
+		requireNoError(t, err, "Decode lenient animation.")
+		assertEqual(t, []int{5, 0}, anim.Delay, "Unexpected lenient animation delays.")
+		assertEqual(t, "⠀⠀⠀\n⣿⠀⠀\n", encodeString(t, anim.Image[0]), "Unexpected lenient animation frame.")
+	})
+	t.Run("all", func(t *testing.T) {
+		decodeLenient(t, "\xef\xbb\xbf\r\n \x1b[32m⣿\x1b[0m  \r\n⣿⣿ ⣿\r\n\r\n", "⠀⣿⠀⠀\n⣿⣿⠀⣿\n")
+	})
+
+	// Make sure the testdata round trips.
+	decodeLenient(t, mustGetFile(t, "testdata/biplane.bug").String(), mustGetFile(t, "testdata/biplane.bug").String())
+}
@@ -0,0 +1,109 @@
+package bug
+
+import (
+	"context"
+	"image"
+)
+
+// Six-dot braille. Many refreshable braille displays only show the top 3
+// rows of the cells, i.e. dots 1 to 6, U+2800 - U+283F. In the 6-dot mode,
+// each cell represents 2x3 pixels, using the same bits as the top 3 rows
+// of offsetMap.
+//
+// As the cells keep the same shape, converted images are scaled vertically
+// by 3/4 to keep their aspect ratio: each dot row combines the 1 or 2 pixel
+// rows it covers, a dot being set when set in any of them, so thin lines
+// are kept. BUG images are encoded as is, their dots being already set.
+
+// sixDotMask holds the bits of the dots 1 to 6.
+const sixDotMask = 0x3f
+
+// sixDotRows returns the number of dot rows of the 6-dot output of an image
+// with the given number of pixel rows, and the range of pixel rows, from y0
+// to y1 excluded, covered by each dot row. Every pixel row is covered once.
+func sixDotRows(height int, scale bool) (int, func(v int) (y0, y1 int)) {
+	if !scale {
+		return height, func(v int) (int, int) { return v, v + 1 }
+	}
+	return (3*height + 3) / 4, func(v int) (int, int) {
+		y0, y1 := 4*v/3, 4*(v+1)/3
+		if y1 > height {
+			y1 = height
+		}
+		return y0, y1
+	}
+}
+
+// encodeSixDots encodes the pixels of b with 2x3 pixels per cell, isDot
+// checking if the given pixel sets a dot. The output starts at b's origin.
+func (e *Encoder) encodeSixDots(ctx context.Context, b image.Rectangle, scale bool, workers int, isDot func(x, y int) bool) error {
+	r, fillRow := sixDotCells(b, scale, isDot)
+	return e.encodeCells(ctx, r, workers, fillRow)
+}
+
+// sixDotCells returns the 6-dot cells of the pixels of b, see encodeSixDots,
+// and a function filling the given cell row.
+func sixDotCells(b image.Rectangle, scale bool, isDot func(x, y int) bool) (image.Rectangle, func(row int, cells []uint8)) {
+	height, pixelRows := sixDotRows(b.Dy(), scale)
+	r := image.Rect(0, 0, (b.Dx()+1)/2, (height+2)/3)
+	return r, func(row int, cells []uint8) {
+		for i := range cells {
+			cells[i] = 0
+		}
+		for v := row * 3; v < row*3+3 && v < height; v++ {
+			y0, y1 := pixelRows(v)
+			for y := b.Min.Y + y0; y < b.Min.Y+y1; y++ {
+				for x := 0; x < b.Dx(); x++ {
+					if isDot(b.Min.X+x, y) {
+						cells[x/2] |= offsetMap[v%3][x%2]
+					}
+				}
+			}
+		}
+	}
+}
+
+// newSixDotGray returns a new image of the given size in cells, with 2x3
+// pixels per cell.
+func newSixDotGray(width, height int, t Threshold) *Gray {
+	return newGray(image.Rect(0, 0, width*2, height*3), t)
+}
+
+// setSixDotCell sets the dots of the given 6-dot cell, ignoring the dots 7
+// and 8, and updates the "real" pixels to their canonical colors.
+func (p *Gray) setSixDotCell(col, row int, cellVal uint8) {
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 2; i++ {
+			x, y := col*2+i, row*3+j
+			dot := cellVal&offsetMap[j][i] != 0
+			p.Gray.SetGray(x, y, p.Threshold.dotColor(dot))
+			p.setDot(x, y, dot)
+		}
+	}
+}
+
+// ScaleSixDots returns a copy of the image scaled vertically by 3/4, the same
+// way the 6-dot encoding scales converted images, each pixel row holding the
+// dots of the pixel rows it covers. Encoding the result with 6 dots then
+// matches encoding its source, while allowing to convert the source with any
+// Options first, e.g. for animations.
+func (p *Gray) ScaleSixDots() *Gray {
+	b := p.Bounds()
+	height, pixelRows := sixDotRows(b.Dy(), true)
+	dst := newGray(image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+height), p.Threshold)
+	dst.Dither, dst.Classifier = p.Dither, p.Classifier
+	dot := p.Threshold.dotColor(true)
+	for v := 0; v < height; v++ {
+		y0, y1 := pixelRows(v)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for y := b.Min.Y + y0; y < b.Min.Y+y1; y++ {
+				if p.isSet(x, y) {
+					dst.Gray.SetGray(x, b.Min.Y+v, dot)
+					dst.setDot(x, b.Min.Y+v, true)
+					break
+				}
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,107 @@
+package bug
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+// encodeSixDots encodes the given image with 6 dots, using Encode and EncodeContext,
+// making sure they agree and stay within the 6 dots range.
+func encodeSixDots(tb testing.TB, img image.Image, crop bool) string {
+	tb.Helper()
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf).WithDots(6)
+	enc.Crop = crop
+	requireNoError(tb, enc.Encode(img), "Encode 6 dots.")
+	expect := buf.String()
+
+	buf.Reset()
+	requireNoError(tb, enc.EncodeContext(context.Background(), img), "EncodeContext 6 dots.")
+	assertEqual(tb, expect, buf.String(), "Encode and EncodeContext disagree.")
+	for _, r := range expect {
+		if r != '\n' && r > brailleCharOffset+sixDotMask {
+			tb.Fatalf("Unexpected rune %U outside of the 6 dots range.", r)
+		}
+	}
+	return expect
+}
+
+func TestSixDots(t *testing.T) {
+	const pattern = `
+		#.#.
+		.#..
+		..##
+		#...
+		....
+		.#.#
+	`
+	img := newPatternImage(pattern)
+	out := encodeSixDots(t, img, false)
+	assertEqual(t, "⠑⠥\n⠡⠠\n", out, "Unexpected 6 dots encoding.")
+
+	// Round trip.
+	for _, mode := range []DecodeMode{DecodeDefault, DecodeStrict, DecodeLenient} {
+		decoded, err := NewDecoder(strings.NewReader(out)).WithDots(6).WithMode(mode).Decode()
+		requireNoError(t, err, "Decode 6 dots with mode %d.", mode)
+		assertEqual(t, "(0,0)-(4,6)", decoded.Bounds(), "Unexpected decoded bounds with mode %d.", mode)
+		assertEqual(t, dotPattern(img), dotPattern(decoded.(*Gray)), "Unexpected decoded dots with mode %d.", mode)
+		assertAgree(t, decoded.(*Gray), "Gray after decoding 6 dots with mode %d.", mode)
+	}
+
+	// The dots 7 and 8 are ignored, unless strict.
+	decoded, err := NewDecoder(strings.NewReader("⣿\n")).WithDots(6).Decode()
+	requireNoError(t, err, "Decode 8 dots cell as 6 dots.")
+	assertEqual(t, "##\n##\n##\n", dotPattern(decoded.(*Gray)), "Unexpected 8 dots cell decoded as 6 dots.")
+	_, err = NewDecoder(strings.NewReader("⠿⡀\n")).WithDots(6).WithMode(DecodeStrict).Decode()
+	var formatErr *FormatError
+	if !errors.Is(err, ErrInvalidRune) || !errors.As(err, &formatErr) || formatErr.Column != 2 {
+		t.Fatalf("Expected an invalid rune error on column 2, got %v.", err)
+	}
+
+	// The binary format only has 8 dots.
+	err = NewEncoder(bytes.NewBuffer(nil)).WithDots(6).EncodeAllBinary(&Animation{Image: []*Gray{img}, Delay: []int{0}})
+	if err == nil {
+		t.Fatal("Expected an error encoding 6 dots binary animations.")
+	}
+}
+
+func TestSixDotsScaling(t *testing.T) {
+	// Converted images keep their aspect ratio: 8 rows make 2 cells.
+	src := newFlatImage(2, 8, 0xff)
+	copy(src.Pix, []uint8{0, 0, 0, 0, 0, 0, 0, 0})
+	assertEqual(t, "⠿\n⠀\n", encodeSixDots(t, src, false), "Unexpected scaled 6 dots encoding.")
+	// While BUG images are encoded as is.
+	assertEqual(t, "⠿\n⠉\n⠀\n", encodeSixDots(t, Convert(src, DefaultThreshold), false), "Unexpected BUG image 6 dots encoding.")
+
+	// Thin lines are kept, whichever row they are on.
+	for y, expect := range []string{"⠁\n⠀\n", "⠂\n⠀\n", "⠄\n⠀\n", "⠄\n⠀\n", "⠀\n⠁\n", "⠀\n⠂\n", "⠀\n⠄\n", "⠀\n⠄\n"} {
+		line := newFlatImage(1, 8, 0xff)
+		line.Pix[y] = 0
+		assertEqual(t, expect, encodeSixDots(t, line, false), "Unexpected 6 dots encoding of a line on row %d.", y)
+	}
+
+	// Any source and bounds, cropped or not.
+	for _, src := range []image.Image{
+		newSquareImage(),
+		newLogoImage(),
+		Convert(newSquareImage(), DefaultThreshold).SubImage(image.Rect(3, 1, 29, 18)),
+		Convert(newSquareImage(), DefaultThreshold).Bitmap(),
+		stripes{rect: image.Rect(-3, 5, 20, 26)},
+	} {
+		for _, crop := range []bool{false, true} {
+			encodeSixDots(t, src, crop)
+		}
+	}
+
+	// Scaling the converted images matches encoding their source.
+	for _, src := range []image.Image{newSquareImage(), newLogoImage(), stripes{rect: image.Rect(-3, 5, 20, 26)}} {
+		scaled := Convert(src, DefaultThreshold).ScaleSixDots()
+		assertEqual(t, encodeSixDots(t, src, false), encodeSixDots(t, scaled, false), "Unexpected scaled image 6 dots encoding.")
+		assertAgree(t, scaled, "Gray after scaling for 6 dots.")
+	}
+}
@@ -0,0 +1,236 @@
+package bug
+
+import (
+	"image"
+	"strings"
+)
+
+// Align is the horizontal alignment of the text.
+type Align int
+
+// Available alignments.
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Rotation is the clockwise rotation of the text, by steps of 90°.
+type Rotation int
+
+// Available rotations.
+const (
+	Rotation0 Rotation = iota
+	Rotation90
+	Rotation180
+	Rotation270
+)
+
+// TextOptions are the text drawing parameters.
+// The zero value uses Font5x7, left aligned, without scaling nor rotation.
+type TextOptions struct {
+	// Font is the font to use. nil means Font5x7.
+	Font *Font
+	// Scale draws each dot of the font as a Scale x Scale square.
+	// 0 means 1.
+	Scale int
+	// Align aligns the text on the anchor point: its left edge, center or
+	// right edge, each line being aligned the same way within the text.
+	Align Align
+	// Rotation rotates the text clockwise around the anchor point.
+	Rotation Rotation
+	// Spacing and LineSpacing are added between the glyphs and the lines,
+	// in dots, on top of the font's ones. Negative values tighten the text.
+	Spacing, LineSpacing int
+}
+
+// textLine is a line of text laid out, relative to the text's top-left.
+type textLine struct {
+	text  string
+	x, y  int // Origin of the line, on its baseline.
+	width int
+}
+
+// layout returns the lines of text laid out and the text's size, in dots,
+// before the rotation.
+func (o *TextOptions) layout(text string) ([]textLine, image.Point) {
+	f, scale := o.font(), o.scale()
+	var lines []textLine
+	var size image.Point
+	for i, s := range strings.Split(text, "\n") {
+		l := textLine{text: s, y: i*(f.Height*scale+o.LineSpacing) + f.Ascent*scale}
+		n := 0
+		for _, r := range s {
+			if g := f.Glyph(r); g != nil {
+				l.width += g.Advance*scale + o.Spacing
+				n++
+			}
+		}
+		if n > 0 {
+			l.width -= o.Spacing
+		}
+		if l.width > size.X {
+			size.X = l.width
+		}
+		lines = append(lines, l)
+	}
+	size.Y = len(lines)*f.Height*scale + (len(lines)-1)*o.LineSpacing
+	for i := range lines {
+		switch o.Align {
+		case AlignCenter:
+			lines[i].x = (size.X - lines[i].width) / 2
+		case AlignRight:
+			lines[i].x = size.X - lines[i].width
+		}
+	}
+	return lines, size
+}
+
+// font returns the font to use.
+func (o *TextOptions) font() *Font {
+	if o.Font == nil {
+		return Font5x7
+	}
+	return o.Font
+}
+
+// scale returns the scale to use.
+func (o *TextOptions) scale() int {
+	if o.Scale < 1 {
+		return 1
+	}
+	return o.Scale
+}
+
+// anchor returns the offset of the text's top-left from the anchor point,
+// before the rotation.
+func (o *TextOptions) anchor(size image.Point) image.Point {
+	switch o.Align {
+	case AlignCenter:
+		return image.Pt(-size.X/2, 0)
+	case AlignRight:
+		return image.Pt(-size.X, 0)
+	}
+	return image.Point{}
+}
+
+// rotate returns the dot at the given offset from the anchor point pt,
+// after the rotation.
+func (o *TextOptions) rotate(pt image.Point, dx, dy int) image.Point {
+	switch o.Rotation & 3 {
+	case Rotation90:
+		return image.Pt(pt.X-dy-1, pt.Y+dx)
+	case Rotation180:
+		return image.Pt(pt.X-dx-1, pt.Y-dy-1)
+	case Rotation270:
+		return image.Pt(pt.X+dy, pt.Y-dx-1)
+	}
+	return image.Pt(pt.X+dx, pt.Y+dy)
+}
+
+// TextBounds returns the bounds of the text DrawText draws at pt
+// with the given options, including the spaces after the last glyph and line.
+// A nil o uses the zero value options.
+func TextBounds(pt image.Point, text string, o *TextOptions) image.Rectangle {
+	if o == nil {
+		o = &TextOptions{}
+	}
+	_, size := o.layout(text)
+	if size.X <= 0 || size.Y <= 0 {
+		return image.Rectangle{Min: pt, Max: pt}
+	}
+	a := o.anchor(size)
+	r := image.Rectangle{
+		Min: o.rotate(pt, a.X, a.Y),
+		Max: o.rotate(pt, a.X+size.X-1, a.Y+size.Y-1),
+	}.Canon()
+	r.Max = r.Max.Add(image.Pt(1, 1))
+	return r
+}
+
+// DrawText draws the dots of the given text on the image, anchored at pt,
+// the top-left of the text when left aligned. Lines are separated by '\n'.
+// Only the dots of the glyphs are set, the others are left as is, and the
+// "real" pixels of the set dots get their canonical color.
+// Returns the bounds of the text, see TextBounds.
+// A nil o uses the zero value options.
+func (p *Gray) DrawText(pt image.Point, text string, o *TextOptions) image.Rectangle {
+	if o == nil {
+		o = &TextOptions{}
+	}
+	f, scale := o.font(), o.scale()
+	lines, size := o.layout(text)
+	a := o.anchor(size)
+	dot := p.Threshold.dotColor(true)
+	for _, l := range lines {
+		x := a.X + l.x
+		for _, r := range l.text {
+			g := f.Glyph(r)
+			if g == nil {
+				continue
+			}
+			m := g.Mask
+			for gy := m.Rect.Min.Y; gy < m.Rect.Max.Y; gy++ {
+				for gx := m.Rect.Min.X; gx < m.Rect.Max.X; gx++ {
+					if m.Pix[m.PixOffset(gx, gy)] == 0 {
+						continue
+					}
+					for sy := 0; sy < scale; sy++ {
+						for sx := 0; sx < scale; sx++ {
+							d := o.rotate(pt, x+gx*scale+sx, a.Y+l.y+gy*scale+sy)
+							if d.In(p.Gray.Rect) {
+								p.Gray.SetGray(d.X, d.Y, dot)
+								p.setDot(d.X, d.Y, true)
+							}
+						}
+					}
+				}
+			}
+			x += g.Advance*scale + o.Spacing
+		}
+	}
+	return TextBounds(pt, text, o)
+}
+
+// NewText returns a new image holding the given text, sized to its bounds
+// and starting at the origin. See DrawText.
+func NewText(text string, o *TextOptions) *Gray {
+	r := TextBounds(image.Point{}, text, o)
+	img := NewGray(r.Sub(r.Min))
+	img.DrawText(image.Point{}.Sub(r.Min), text, o)
+	return img
+}
+
+// WrapText wraps the words of the text so each line fits within width dots
+// when drawn with the given options, before the rotation, breaking the words
+// too long for a line. The existing line breaks are kept, and the spaces
+// between the words are collapsed.
+// A nil o uses the zero value options.
+func WrapText(text string, width int, o *TextOptions) string {
+	fits := func(s string) bool {
+		return TextBounds(image.Point{}, s, o).Dx() <= width
+	}
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && fits(line+" "+word) {
+				line += " " + word
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			for _, r := range word {
+				if line != "" && !fits(line+string(r)) {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
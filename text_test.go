@@ -0,0 +1,182 @@
+package bug
+
+import (
+	"image"
+	"testing"
+)
+
+func TestNewText(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		text   string
+		opts   *TextOptions
+		expect string
+	}{
+		{"center", "Hi\nBUG", &TextOptions{Font: Font3x5, Align: AlignCenter}, `
+			..#.#.###...
+			..#.#..#....
+			..###..#....
+			..#.#..#....
+			..#.#.###...
+			............
+			##..#.#..##.
+			#.#.#.#.#...
+			##..#.#.#.#.
+			#.#.#.#.#.#.
+			##..###..##.
+			............
+		`},
+		{"right", "Hi\nBUG", &TextOptions{Font: Font3x5, Align: AlignRight}, `
+			....#.#.###.
+			....#.#..#..
+			....###..#..
+			....#.#..#..
+			....#.#.###.
+			............
+			##..#.#..##.
+			#.#.#.#.#...
+			##..#.#.#.#.
+			#.#.#.#.#.#.
+			##..###..##.
+			............
+		`},
+		{"rotated", "Hi", &TextOptions{Font: Font3x5, Rotation: Rotation90}, `
+			.#####
+			...#..
+			.#####
+			......
+			.#...#
+			.#####
+			.#...#
+			......
+		`},
+		{"scaled", "Hi", &TextOptions{Font: Font3x5, Scale: 2}, `
+			##..##..######..
+			##..##..######..
+			##..##....##....
+			##..##....##....
+			######....##....
+			######....##....
+			##..##....##....
+			##..##....##....
+			##..##..######..
+			##..##..######..
+			................
+			................
+		`},
+		{"spacing", "Hi\nHi", &TextOptions{Font: Font3x5, Spacing: -1, LineSpacing: -2}, `
+			#.####.
+			#.#.#..
+			###.#..
+			#.#.#..
+			#.####.
+			#.#.#..
+			###.#..
+			#.#.#..
+			#.####.
+			.......
+		`},
+		{"missing", "é", &TextOptions{Font: Font3x5}, `
+			###.
+			..#.
+			.##.
+			....
+			.#..
+			....
+		`},
+	} {
+		img := NewText(tt.text, tt.opts)
+		assertEqual(t, dotPattern(newPatternImage(tt.expect)), dotPattern(img), "Unexpected %s text.", tt.name)
+		assertAgree(t, img, "Gray after %s text.", tt.name)
+	}
+
+	// The rotations match the image ones.
+	text, o := "Hello,\nWorld!", &TextOptions{Align: AlignCenter, Scale: 2}
+	upright := NewText(text, o)
+	for rotation, expect := range map[Rotation]*Gray{
+		Rotation90:  upright.Rotate90(),
+		Rotation180: upright.Rotate180(),
+		Rotation270: upright.Rotate270(),
+	} {
+		o.Rotation = rotation
+		img := NewText(text, o)
+		assertEqual(t, expect.Bounds(), img.Bounds(), "Unexpected bounds with rotation %d.", rotation)
+		assertEqual(t, dotPattern(expect), dotPattern(img), "Unexpected text with rotation %d.", rotation)
+	}
+
+	assertEqual(t, image.Rectangle{}, NewText("", nil).Bounds(), "Unexpected empty text bounds.")
+}
+
+func TestDrawText(t *testing.T) {
+	// The dots are drawn within the bounds, around the anchor point.
+	pt := image.Pt(21, 13)
+	for _, align := range []Align{AlignLeft, AlignCenter, AlignRight} {
+		for _, rotation := range []Rotation{Rotation0, Rotation90, Rotation180, Rotation270} {
+			o := &TextOptions{Align: align, Rotation: rotation}
+			img := NewGray(image.Rect(-30, -30, 70, 60))
+			r := img.DrawText(pt, "BUG\nbug", o)
+			assertEqual(t, TextBounds(pt, "BUG\nbug", o), r, "Unexpected bounds with %+v.", o)
+			assertEqual(t, 18*16, r.Dx()*r.Dy(), "Unexpected bounds size with %+v.", o)
+
+			var dots image.Rectangle
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if img.isSet(x, y) {
+						dots = dots.Union(image.Rect(x, y, x+1, y+1))
+					}
+				}
+			}
+			if !dots.In(r) {
+				t.Fatalf("Dots %v outside of the bounds %v with %+v.", dots, r, o)
+			}
+			if align == AlignLeft && rotation == Rotation0 && dots.Min != pt {
+				t.Fatalf("Unexpected top-left dot %v, expected %v.", dots.Min, pt)
+			}
+			assertAgree(t, img, "Gray after drawing with %+v.", o)
+		}
+	}
+
+	// Only the dots of the glyphs are set, clipped to the image.
+	img := newPatternImage(`
+		########
+		........
+		........
+		........
+	`)
+	img.DrawText(image.Pt(-1, 1), "H", &TextOptions{Font: Font3x5})
+	assertEqual(t, dotPattern(newPatternImage(`
+		########
+		.#......
+		.#......
+		##......
+	`)), dotPattern(img), "Unexpected clipped text.")
+	assertAgree(t, img, "Gray after clipped text.")
+
+	// Inverse threshold.
+	img = newGray(image.Rect(0, 0, 4, 6), DefaultThreshold.Inverse())
+	img.DrawText(image.Point{}, "H", &TextOptions{Font: Font3x5})
+	assertEqual(t, dotPattern(NewText("H", &TextOptions{Font: Font3x5})), dotPattern(img), "Unexpected inverse text.")
+	assertAgree(t, img, "Gray after inverse text.")
+}
+
+func TestWrapText(t *testing.T) {
+	o := &TextOptions{Font: Font3x5} // 4 dots per glyph.
+	for _, tt := range []struct {
+		text   string
+		width  int
+		expect string
+	}{
+		{"Hi Hi", 20, "Hi Hi"},
+		{"Hi Hi", 19, "Hi\nHi"},
+		{"Hi  there\n\nbug", 16, "Hi\nther\ne\n\nbug"},
+		{"BUGGY", 8, "BU\nGG\nY"},
+		{"a b c d", 12, "a b\nc d"},
+		{"", 8, ""},
+	} {
+		assertEqual(t, tt.expect, WrapText(tt.text, tt.width, o), "Unexpected wrapped %q in %d dots.", tt.text, tt.width)
+	}
+
+	// Without options, using the default font.
+	assertEqual(t, "Hi\nHi", WrapText("Hi Hi", 12, nil), "Unexpected wrapped text with the default font.")
+}
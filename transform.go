@@ -0,0 +1,245 @@
+package bug
+
+import (
+	"image"
+	"math"
+)
+
+// Exact transforms. When the image is aligned on the cell grid, the cells
+// are permuted using lookup tables, otherwise the dots are moved one by one.
+// The retained grayscale pixels are moved along the dots.
+
+// Per cell lookup tables, derived from offsetMap.
+// flipHCell and flipVCell mirror a cell, cellRow[cell][row] extracts a row
+// as 2 bits, left to right from the lowest bit.
+var flipHCell, flipVCell, cellRow = newTransformTables()
+
+func newTransformTables() (flipHCell, flipVCell [256]uint8, cellRow [256][4]uint8) {
+	for cell := 0; cell < 256; cell++ {
+		for row := 0; row < 4; row++ {
+			for col := 0; col < 2; col++ {
+				if uint8(cell)&offsetMap[row][col] == 0 {
+					continue
+				}
+				flipHCell[cell] |= offsetMap[row][1-col]
+				flipVCell[cell] |= offsetMap[3-row][col]
+				cellRow[cell][row] |= 1 << uint(col)
+			}
+		}
+	}
+	return flipHCell, flipVCell, cellRow
+}
+
+// isCellAligned checks if r is made of whole cells.
+func isCellAligned(r image.Rectangle) bool {
+	return r.Min.X%2 == 0 && r.Min.Y%4 == 0 && r.Dx()%2 == 0 && r.Dy()%4 == 0
+}
+
+// FlipH returns a copy of the image mirrored horizontally, with the same bounds.
+func (p *Gray) FlipH() *Gray {
+	b, c := p.Bounds(), p.Rect
+	return p.remap(b, func(x, y int) (int, int) {
+		return b.Min.X + b.Max.X - 1 - x, y
+	}, func(col, row int) uint8 {
+		return flipHCell[p.cellAt(c.Min.X+c.Max.X-1-col, row)]
+	})
+}
+
+// FlipV returns a copy of the image mirrored vertically, with the same bounds.
+func (p *Gray) FlipV() *Gray {
+	b, c := p.Bounds(), p.Rect
+	return p.remap(b, func(x, y int) (int, int) {
+		return x, b.Min.Y + b.Max.Y - 1 - y
+	}, func(col, row int) uint8 {
+		return flipVCell[p.cellAt(col, c.Min.Y+c.Max.Y-1-row)]
+	})
+}
+
+// Rotate180 returns a copy of the image rotated by 180°, with the same bounds.
+func (p *Gray) Rotate180() *Gray {
+	b, c := p.Bounds(), p.Rect
+	return p.remap(b, func(x, y int) (int, int) {
+		return b.Min.X + b.Max.X - 1 - x, b.Min.Y + b.Max.Y - 1 - y
+	}, func(col, row int) uint8 {
+		return flipHCell[flipVCell[p.cellAt(c.Min.X+c.Max.X-1-col, c.Min.Y+c.Max.Y-1-row)]]
+	})
+}
+
+// Transpose returns a copy of the image mirrored along its top-left to
+// bottom-right diagonal. The result has the same origin, with the width
+// and height swapped.
+func (p *Gray) Transpose() *Gray {
+	b, c := p.Bounds(), p.Rect
+	r := image.Rectangle{Min: b.Min, Max: b.Min.Add(image.Pt(b.Dy(), b.Dx()))}
+	return p.remap(r, func(x, y int) (int, int) {
+		return b.Min.X + y - b.Min.Y, b.Min.Y + x - b.Min.X
+	}, func(col, row int) uint8 {
+		// Each cell column is a source row spanning 2 source cells.
+		// With whole cells, the local cell coordinates are the same.
+		lcol, lrow := col-c.Min.X, row-c.Min.Y
+		var cell uint8
+		for k := 0; k < 2; k++ {
+			y := 2*lcol + k // Local source row.
+			left := p.cellAt(c.Min.X+2*lrow, c.Min.Y+y/4)
+			right := p.cellAt(c.Min.X+2*lrow+1, c.Min.Y+y/4)
+			cell |= nibbleColumn[cellRow[left][y%4]|cellRow[right][y%4]<<2][k]
+		}
+		return cell
+	})
+}
+
+// Rotate90 returns a copy of the image rotated by 90° clockwise.
+// The result has the same origin, with the width and height swapped.
+func (p *Gray) Rotate90() *Gray {
+	return p.Transpose().FlipH()
+}
+
+// Rotate270 returns a copy of the image rotated by 90° counterclockwise.
+// The result has the same origin, with the width and height swapped.
+func (p *Gray) Rotate270() *Gray {
+	return p.Transpose().FlipV()
+}
+
+// remap returns a new image of bounds r, each pixel x,y being copied from
+// the pixel at(x, y) of p. When both images are aligned on the cell grid,
+// the cells are set from cellAt instead of dot by dot.
+func (p *Gray) remap(r image.Rectangle, at func(x, y int) (int, int), cellAt func(col, row int) uint8) *Gray {
+	dst := newGray(r, p.Threshold)
+	dst.Dither, dst.Classifier = p.Dither, p.Classifier
+
+	aligned := isCellAligned(p.Bounds()) && isCellAligned(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sx, sy := at(x, y)
+			dst.Gray.Pix[dst.Gray.PixOffset(x, y)] = p.Gray.Pix[p.Gray.PixOffset(sx, sy)]
+			if !aligned {
+				dst.setDot(x, y, p.isSet(sx, sy))
+			}
+		}
+	}
+	if aligned {
+		for row := dst.Rect.Min.Y; row < dst.Rect.Max.Y; row++ {
+			for col := dst.Rect.Min.X; col < dst.Rect.Max.X; col++ {
+				dst.content[row-dst.Rect.Min.Y][col-dst.Rect.Min.X] = cellAt(col, row)
+			}
+		}
+	}
+	return dst
+}
+
+// Resampled transforms. The result is resampled from the retained grayscale
+// pixels, using a bilinear interpolation, and thresholded again.
+
+// Affine is a 2D affine transformation matrix, mapping the source point x,y
+// to x*m[0] + y*m[1] + m[2], x*m[3] + y*m[4] + m[5].
+// Mirrors golang.org/x/image/math/f64.Aff3.
+type Affine [6]float64
+
+// Identity is the identity transformation.
+var Identity = Affine{1, 0, 0, 0, 1, 0}
+
+// invert returns the inverse transformation, false if not invertible.
+func (m Affine) invert() (Affine, bool) {
+	det := m[0]*m[4] - m[1]*m[3]
+	if det == 0 {
+		return Affine{}, false
+	}
+	return Affine{
+		m[4] / det, -m[1] / det, (m[1]*m[5] - m[2]*m[4]) / det,
+		-m[3] / det, m[0] / det, (m[2]*m[3] - m[0]*m[5]) / det,
+	}, true
+}
+
+// apply returns the transformed point x,y.
+func (m Affine) apply(x, y float64) (float64, float64) {
+	return x*m[0] + y*m[1] + m[2], x*m[3] + y*m[4] + m[5]
+}
+
+// Transform returns a new image of bounds r holding the image transformed by m.
+// The pixels of r mapping outside the image, or all of them when m is not
+// invertible, are empty.
+// See Rotate90, FlipH, etc. for exact transforms.
+func (p *Gray) Transform(r image.Rectangle, m Affine) *Gray {
+	dst := newGray(r, p.Threshold)
+	dst.Dither, dst.Classifier = p.Dither, p.Classifier
+
+	inv, ok := m.invert()
+	if !ok {
+		return dst
+	}
+	empty := p.Threshold.dotColor(false).Y
+	luma := make([]uint8, r.Dx())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			// Sample at the pixel's center.
+			sx, sy := inv.apply(float64(x)+0.5, float64(y)+0.5)
+			luma[x-r.Min.X] = p.bilinear(sx, sy, empty)
+		}
+		dst.setLumaRow(r.Min.X, y, luma)
+	}
+	return dst
+}
+
+// bilinear returns the grayscale value interpolated at the point x,y,
+// or empty outside the image.
+func (p *Gray) bilinear(x, y float64, empty uint8) uint8 {
+	b := p.Bounds()
+	if x < float64(b.Min.X) || y < float64(b.Min.Y) || x >= float64(b.Max.X) || y >= float64(b.Max.Y) {
+		return empty
+	}
+	// Relative to the pixel centers, clamped at the edges.
+	x, y = x-0.5, y-0.5
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	clampX := func(x int) int {
+		if x < b.Min.X {
+			return b.Min.X
+		}
+		if x >= b.Max.X {
+			return b.Max.X - 1
+		}
+		return x
+	}
+	clampY := func(y int) int {
+		if y < b.Min.Y {
+			return b.Min.Y
+		}
+		if y >= b.Max.Y {
+			return b.Max.Y - 1
+		}
+		return y
+	}
+	at := func(x, y int) float64 {
+		return float64(p.Gray.Pix[p.Gray.PixOffset(clampX(x), clampY(y))])
+	}
+	v := (at(x0, y0)*(1-fx)+at(x0+1, y0)*fx)*(1-fy) + (at(x0, y0+1)*(1-fx)+at(x0+1, y0+1)*fx)*fy
+	return uint8(math.Round(v))
+}
+
+// Rotate returns a copy of the image rotated by theta radians clockwise
+// around its center. The result is large enough to hold the whole rotated
+// image, centered on the same point.
+func (p *Gray) Rotate(theta float64) *Gray {
+	b := p.Bounds()
+	cx, cy := float64(b.Min.X+b.Max.X)/2, float64(b.Min.Y+b.Max.Y)/2
+	sin, cos := math.Sincos(theta)
+	// Translate the center to the origin, rotate, translate back.
+	m := Affine{
+		cos, -sin, cx - cos*cx + sin*cy,
+		sin, cos, cy - sin*cx - cos*cy,
+	}
+
+	// Bounding box of the rotated corners. Rounding drops the float noise.
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range [4][2]int{{b.Min.X, b.Min.Y}, {b.Max.X, b.Min.Y}, {b.Min.X, b.Max.Y}, {b.Max.X, b.Max.Y}} {
+		x, y := m.apply(float64(c[0]), float64(c[1]))
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	const eps = 1e-9
+	r := image.Rect(
+		int(math.Floor(minX+eps)), int(math.Floor(minY+eps)),
+		int(math.Ceil(maxX-eps)), int(math.Ceil(maxY-eps)),
+	)
+	return p.Transform(r, m)
+}
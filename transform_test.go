@@ -0,0 +1,100 @@
+package bug
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Test the exact transforms against a per pixel reference,
+// on images aligned on the cell grid or not.
+func TestTransformExact(t *testing.T) {
+	newNoise := func(r image.Rectangle) *Gray {
+		return Convert(newNoiseImage(func(r image.Rectangle) image.Image { return image.NewRGBA(r) }, r), DefaultThreshold)
+	}
+	type mapping func(b image.Rectangle, x, y int) (int, int)
+	transforms := map[string]struct {
+		transform func(*Gray) *Gray
+		at        mapping // Source pixel of the given result pixel.
+	}{
+		"fliph": {(*Gray).FlipH, func(b image.Rectangle, x, y int) (int, int) { return b.Min.X + b.Max.X - 1 - x, y }},
+		"flipv": {(*Gray).FlipV, func(b image.Rectangle, x, y int) (int, int) { return x, b.Min.Y + b.Max.Y - 1 - y }},
+		"rotate180": {(*Gray).Rotate180, func(b image.Rectangle, x, y int) (int, int) {
+			return b.Min.X + b.Max.X - 1 - x, b.Min.Y + b.Max.Y - 1 - y
+		}},
+		"transpose": {(*Gray).Transpose, func(b image.Rectangle, x, y int) (int, int) {
+			return b.Min.X + y - b.Min.Y, b.Min.Y + x - b.Min.X
+		}},
+		"rotate90": {(*Gray).Rotate90, func(b image.Rectangle, x, y int) (int, int) {
+			return b.Min.X + y - b.Min.Y, b.Max.Y - 1 - (x - b.Min.X)
+		}},
+		"rotate270": {(*Gray).Rotate270, func(b image.Rectangle, x, y int) (int, int) {
+			return b.Max.X - 1 - (y - b.Min.Y), b.Min.Y + x - b.Min.X
+		}},
+	}
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 16, 24),  // Aligned.
+		image.Rect(-4, 8, 12, 16), // Aligned, not for the 90° turns.
+		image.Rect(1, 3, 14, 10),  // Unaligned.
+	}
+
+	for name, tt := range transforms {
+		for _, r := range rects {
+			src := newNoise(r)
+			src.Set(r.Min.X, r.Min.Y, color.Opaque) // Special case color, stored as the canonical dot color.
+			dst := tt.transform(src)
+			b := dst.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					sx, sy := tt.at(r, x, y)
+					if dst.isSet(x, y) != src.isSet(sx, sy) || dst.GrayAt(x, y) != src.GrayAt(sx, sy) {
+						t.Fatalf("Unexpected %s pixel %d,%d for %v.", name, x, y, r)
+					}
+				}
+			}
+			assertAgree(t, dst, "Gray after %s.", name)
+		}
+	}
+}
+
+// Test the exact transforms on a small sprite.
+func TestTransformSprite(t *testing.T) {
+	decoded, err := Decode(strings.NewReader("⣇⡀\n"))
+	requireNoError(t, err, "Decode sprite.")
+	img := decoded.(*Gray)
+
+	assertEqual(t, "⢀⣸\n", encodeString(t, img.FlipH()), "Unexpected FlipH.")
+	assertEqual(t, "⡏⠁\n", encodeString(t, img.FlipV()), "Unexpected FlipV.")
+	assertEqual(t, "⠈⢹\n", encodeString(t, img.Rotate180()), "Unexpected Rotate180.")
+	assertEqual(t, "⣇⡀\n", encodeString(t, img.Rotate270().Rotate90().Rotate90().Rotate270().Transpose().Transpose().Rotate90().Rotate270()), "Unexpected round trip.")
+	assertEqual(t, encodeString(t, img.Rotate90().Rotate90()), encodeString(t, img.Rotate180()), "Unexpected Rotate90 twice.")
+}
+
+// Test the resampled transforms.
+func TestTransformResampled(t *testing.T) {
+	src := Convert(newNoiseImage(func(r image.Rectangle) image.Image { return image.NewRGBA(r) }, image.Rect(-3, 2, 17, 22)), DefaultThreshold)
+
+	// Identity and translations land on the pixel centers.
+	assertSameImage(t, src, src.Transform(src.Bounds(), Identity), "Unexpected identity transform.")
+	moved := src.Transform(src.Bounds().Add(image.Pt(5, -1)), Affine{1, 0, 5, 0, 1, -1})
+	assertEqual(t, src.GrayAt(0, 10), moved.GrayAt(5, 9), "Unexpected translated pixel.")
+	assertEqual(t, src.Bounds().Add(image.Pt(5, -1)), moved.Bounds(), "Unexpected translated bounds.")
+
+	// Not invertible, empty.
+	empty := src.Transform(src.Bounds(), Affine{})
+	empty.RangeCells(func(col, row int, v uint8) bool {
+		assertEqual(t, 0, v, "Unexpected cell %d,%d for a non invertible transform.", col, row)
+		return v == 0
+	})
+	inverse := Convert(src, DefaultThreshold.Inverse())
+	assertAgree(t, inverse.Transform(src.Bounds(), Affine{}), "Gray after a non invertible inverse transform.")
+	assertAgree(t, inverse.Rotate(math.Pi/4), "Gray after an inverse rotation.")
+
+	// Rotations around the center.
+	assertSameImage(t, src, src.Rotate(0), "Unexpected null rotation.")
+	assertSameImage(t, src.Rotate180(), src.Rotate(math.Pi), "Unexpected half turn.")
+	assertSameImage(t, src.Rotate90(), src.Rotate(math.Pi/2), "Unexpected quarter turn.")
+	assertEqual(t, image.Rect(-8, -3, 22, 27), src.Rotate(math.Pi/4).Bounds(), "Unexpected rotated bounds.")
+}
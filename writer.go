@@ -1,8 +1,9 @@
 package bug
 
 import (
+	"context"
 	"image"
-	"image/draw"
+	"image/color"
 	"io"
 	"unicode/utf8"
 )
@@ -16,6 +17,48 @@ func Encode(w io.Writer, img image.Image) error {
 type Encoder struct {
 	w io.Writer
 	Threshold
+
+	// Classifier, when set, is used instead of Threshold to classify the colors.
+	Classifier Classifier
+	// Background and AlphaCutoff control the transparent pixels, see Options.
+	Background  color.Color
+	AlphaCutoff uint8
+
+	// Workers is the number of goroutines converting and encoding the image,
+	// by bands of cell rows, e.g. runtime.GOMAXPROCS(0). 0 or 1 encodes
+	// serially, so image.Image implementations need not be safe for
+	// concurrent use unless asked. The output is the same regardless.
+	Workers int
+
+	// Dots is the number of dots per cell: 8 by default, or 6 for the braille
+	// displays only showing U+2800 - U+283F, each cell representing 2x3 pixels.
+	// Converted images are then scaled vertically by 3/4, see sixdot.go,
+	// while BUG images are encoded as is, see Gray.ScaleSixDots.
+	// Not supported by the binary variant of the animation format.
+	Dots int
+
+	// Output formatting, to embed the result in source code, chats or emails.
+	// The zero values produce the regular BUG format. Others may require
+	// DecodeLenient to be decoded, or can't be decoded at all.
+
+	// Crop crops the output to the bounding box of the set braille points,
+	// a single blank cell for blank images.
+	Crop bool
+	// TrimRight removes the trailing empty cells of each line, keeping one
+	// on blank lines.
+	TrimRight bool
+	// Blank is the rune used for the empty cells, e.g. ' '. 0 means U+2800.
+	Blank rune
+	// CRLF ends the lines with "\r\n" instead of "\n".
+	CRLF bool
+	// Margin is the number of empty cells added around the image.
+	Margin int
+	// Prefix is written at the start of each line, e.g. "// ".
+	Prefix string
+
+	// frameCrop, when set, replaces the Crop bounding box, relative to the
+	// output origin, so all the frames of an animation are cropped alike.
+	frameCrop *image.Rectangle
 }
 
 // NewEncoder returns a default encoder.
@@ -23,30 +66,388 @@ func NewEncoder(w io.Writer) *Encoder {
 	return &Encoder{w: w, Threshold: DefaultThreshold}
 }
 
+// WithWorkers sets the number of goroutines to use.
+func (e *Encoder) WithWorkers(n int) *Encoder {
+	e.Workers = n
+	return e
+}
+
+// WithDots sets the number of dots per cell, 8 or 6.
+func (e *Encoder) WithDots(n int) *Encoder {
+	e.Dots = n
+	return e
+}
+
+// Encode the given image. BUG images (Gray and Bitmap) are encoded as is,
+// ignoring the encoder's threshold, others get converted first.
+// See EncodeContext to avoid the conversion's allocations.
 func (e *Encoder) Encode(img image.Image) error {
-	bugImg := Convert(img, e.Threshold)
-	line := make([]byte, bugImg.Rect.Dx()*3+1) // 3 bytes per braille rune. + 1 for the newline.
-	line[bugImg.Rect.Dx()*3] = '\n'
-	for _, row := range bugImg.content {
-		for i, cell := range row {
-			utf8.EncodeRune(line[i*3:], rune(cell)+brailleCharOffset)
+	r, fillRow := e.imageCells(img)
+	return e.encodeCells(context.Background(), r, e.Workers, fillRow)
+}
+
+// imageCells returns the cells to encode for the given image, converted
+// first if needed, and a function filling the given cell row.
+func (e *Encoder) imageCells(img image.Image) (image.Rectangle, func(row int, cells []uint8)) {
+	bugImg, ok := img.(cellImage)
+	if !ok {
+		bugImg = ConvertWithOptions(img, &Options{
+			Threshold:   e.Threshold,
+			Classifier:  e.Classifier,
+			Background:  e.Background,
+			AlphaCutoff: e.AlphaCutoff,
+			Workers:     e.Workers,
+		})
+	}
+	if e.Dots == 6 {
+		return sixDotCells(img.Bounds(), !ok, e.dotFunc(bugImg))
+	}
+	// Like other formats, the output starts at the image's bounds origin.
+	bugImg = alignCells(bugImg)
+	return bugImg.cellBounds(), cellAtRow(bugImg)
+}
+
+// EncodeContext encodes the given image like Encode, without converting it first:
+// the pixels are read four rows at a time and each output line is written as
+// soon as it is built, so memory use is bounded by one cell row regardless of
+// the image's height. The encoding is always done serially, ignoring Workers.
+// When cropping, the image is read twice.
+// The context is checked before each line, its error being returned when done.
+func (e *Encoder) EncodeContext(ctx context.Context, img image.Image) error {
+	b := img.Bounds()
+	if e.Dots == 6 {
+		_, ok := img.(cellImage)
+		return e.encodeSixDots(ctx, b, !ok, 1, e.dotFunc(img))
+	}
+	if bugImg, ok := img.(cellImage); ok && b.Min.X%2 == 0 && b.Min.Y%4 == 0 {
+		// Aligned BUG images already have the cells.
+		return e.encodeCells(ctx, bugImg.cellBounds(), 1, cellAtRow(bugImg))
+	}
+
+	isDot := e.dotFunc(img)
+	return e.encodeCells(ctx, cellRect(image.Rect(0, 0, b.Dx(), b.Dy())), 1, func(row int, cells []uint8) {
+		for i := range cells {
+			cells[i] = 0
+		}
+		// Like other formats, the output starts at the image's bounds origin.
+		for y := row * 4; y < row*4+4 && y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				if isDot(b.Min.X+x, b.Min.Y+y) {
+					cells[x/2] |= unicodeOffset(x, y)
+				}
+			}
+		}
+	})
+}
+
+// cellAtRow returns a function filling the given cell row of img.
+func cellAtRow(img cellImage) func(row int, cells []uint8) {
+	r := img.cellBounds()
+	return func(row int, cells []uint8) {
+		for col := r.Min.X; col < r.Max.X; col++ {
+			cells[col-r.Min.X] = img.cellAt(col, row)
 		}
-		if _, err := e.w.Write(line); err != nil {
-			return err
+	}
+}
+
+// encodeCells writes the cells of r, formatted, using the given number of workers.
+// fillRow fills the given cell row, for the whole r width, and must be safe to
+// call concurrently on different rows.
+func (e *Encoder) encodeCells(ctx context.Context, r image.Rectangle, workers int, fillRow func(row int, cells []uint8)) error {
+	full := r
+	switch {
+	case e.Crop && e.frameCrop != nil:
+		r = e.frameCrop.Add(full.Min).Intersect(full)
+	case e.Crop:
+		r = cropCells(full, fillRow)
+	}
+	// cellsOf returns the cells to output from a full row.
+	cellsOf := func(cells []uint8) []uint8 {
+		return cells[r.Min.X-full.Min.X : r.Max.X-full.Min.X]
+	}
+
+	// A blank image cropped to nothing keeps a single blank cell,
+	// so the output can still be decoded.
+	blankCrop := e.Crop && r.Empty()
+	width := r.Dx()
+	if blankCrop {
+		width = 1
+	}
+	blank := make([]uint8, width)
+	line := make([]byte, 0, len(e.Prefix)+(width+2*e.Margin)*utf8.UTFMax+2)
+	for i := 0; i < e.Margin; i++ {
+		line = e.appendLine(line, blank)
+	}
+	if blankCrop {
+		line = e.appendLine(line, blank)
+	}
+	if _, err := e.w.Write(line); err != nil {
+		return err
+	}
+
+	bands := splitBands(r.Min.Y, r.Max.Y, workers)
+	if len(bands) == 1 {
+		cells := make([]uint8, full.Dx())
+		for row := r.Min.Y; row < r.Max.Y; row++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			fillRow(row, cells)
+			if _, err := e.w.Write(e.appendLine(line[:0], cellsOf(cells))); err != nil {
+				return err
+			}
+		}
+	} else {
+		// Encode the bands concurrently, writing them in order as they are done.
+		bufs := make([][]byte, len(bands))
+		done := make([]chan struct{}, len(bands))
+		for i, b := range bands {
+			done[i] = make(chan struct{})
+			go func(i int, b band) {
+				defer close(done[i])
+				cells := make([]uint8, full.Dx())
+				for row := b.Min; row < b.Max; row++ {
+					fillRow(row, cells)
+					bufs[i] = e.appendLine(bufs[i], cellsOf(cells))
+				}
+			}(i, b)
 		}
+		for i := range bands {
+			<-done[i]
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if _, err := e.w.Write(bufs[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	line = line[:0]
+	for i := 0; i < e.Margin; i++ {
+		line = e.appendLine(line, blank)
 	}
-	return nil
+	_, err := e.w.Write(line)
+	return err
+}
+
+// cropCells returns the bounding box of the non empty cells of r,
+// fillRow filling the given cell row for the whole r width.
+func cropCells(r image.Rectangle, fillRow func(row int, cells []uint8)) image.Rectangle {
+	var crop image.Rectangle
+	cells := make([]uint8, r.Dx())
+	for row := r.Min.Y; row < r.Max.Y; row++ {
+		fillRow(row, cells)
+		first, last := -1, -1
+		for i, cell := range cells {
+			if cell != 0 {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+		if first >= 0 {
+			crop = crop.Union(image.Rect(r.Min.X+first, row, r.Min.X+last+1, row+1))
+		}
+	}
+	return crop
+}
+
+// appendLine appends the formatted line of the given cells to buf.
+func (e *Encoder) appendLine(buf []byte, cells []uint8) []byte {
+	buf = append(buf, e.Prefix...)
+	if e.TrimRight {
+		for len(cells) > 0 && cells[len(cells)-1] == 0 {
+			cells = cells[:len(cells)-1]
+		}
+	}
+	if len(cells) == 0 && e.TrimRight {
+		// Keep a blank cell so the line still decodes as a row.
+		buf = e.appendCell(buf, 0)
+	} else {
+		for i := 0; i < e.Margin; i++ {
+			buf = e.appendCell(buf, 0)
+		}
+		for _, cell := range cells {
+			buf = e.appendCell(buf, cell)
+		}
+		if !e.TrimRight {
+			for i := 0; i < e.Margin; i++ {
+				buf = e.appendCell(buf, 0)
+			}
+		}
+	}
+	if e.CRLF {
+		buf = append(buf, '\r')
+	}
+	return append(buf, '\n')
+}
+
+// appendCell appends the rune of the given cell to buf.
+func (e *Encoder) appendCell(buf []byte, cell uint8) []byte {
+	r := rune(cell) + brailleCharOffset
+	if cell == 0 && e.Blank != 0 {
+		r = e.Blank
+	}
+	var tmp [utf8.UTFMax]byte
+	n := utf8.EncodeRune(tmp[:], r)
+	return append(buf, tmp[:n]...)
+}
+
+// dotFunc returns a function checking if the given pixel of img sets a braille
+// point, the same way as Convert does, reading the pixels directly when possible.
+func (e *Encoder) dotFunc(img image.Image) func(x, y int) bool {
+	if bugImg, ok := img.(cellImage); ok {
+		return func(x, y int) bool {
+			return bugImg.cellAt(floorDiv(x, 2), floorDiv(y, 4))&unicodeOffset(x, y) != 0
+		}
+	}
+	isDot := e.colorDotFunc(img)
+	if e.AlphaCutoff == 0 {
+		return isDot
+	}
+	return func(x, y int) bool {
+		return alphaAt(img, x, y) >= e.AlphaCutoff && isDot(x, y)
+	}
+}
+
+// colorDotFunc returns a function checking if the color of the given pixel of
+// the non BUG img sets a braille point, ignoring the alpha cutoff.
+func (e *Encoder) colorDotFunc(img image.Image) func(x, y int) bool {
+	t := e.Threshold
+	if e.Background != nil {
+		return func(x, y int) bool {
+			c := over(img.At(x, y), e.Background)
+			if e.Classifier != nil {
+				return e.Classifier.IsDot(c)
+			}
+			return t.isDotY(luma(uint32(c.R)*0x101, uint32(c.G)*0x101, uint32(c.B)*0x101))
+		}
+	}
+	if e.Classifier != nil {
+		return func(x, y int) bool {
+			return e.Classifier.IsDot(img.At(x, y))
+		}
+	}
+	switch img := img.(type) {
+	case *image.Gray:
+		return func(x, y int) bool {
+			return t.isDotY(img.Pix[img.PixOffset(x, y)])
+		}
+	case *image.RGBA:
+		return func(x, y int) bool {
+			i := img.PixOffset(x, y)
+			return t.isDotY(rgbaLuma(img.Pix[i : i+3]))
+		}
+	case interface{ RGBA64At(x, y int) color.RGBA64 }:
+		// Avoids the color.Color allocations. Not image.RGBA64Image,
+		// which requires Go 1.17.
+		return func(x, y int) bool {
+			c := img.RGBA64At(x, y)
+			return t.isDotY(luma(uint32(c.R), uint32(c.G), uint32(c.B)))
+		}
+	}
+	return func(x, y int) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return t.isDotY(luma(r, g, b))
+	}
+}
+
+// Options are the conversion parameters.
+type Options struct {
+	// Threshold to toogle braille point based on gray scale.
+	Threshold Threshold
+	// Dither mode applied on top of the threshold. Converting a BUG image
+	// with NoDither keeps its own Dither.
+	Dither Dither
+	// Classifier, when set, is used instead of Threshold and Dither to
+	// classify the colors. Filters and the other modes than ModeFill only use the luminance.
+	Classifier Classifier
+	// Background, when set, is the color the image is composited over
+	// first, e.g. color.White for logos with transparency. By default,
+	// transparent pixels get their premultiplied color, i.e. black.
+	Background color.Color
+	// AlphaCutoff, when not 0, removes the dots of the pixels with an alpha,
+	// from 0 to 255, below it regardless of their color.
+	AlphaCutoff uint8
+	// Workers is the number of goroutines converting the image, by bands
+	// of cell rows, e.g. runtime.GOMAXPROCS(0). 0 or 1 converts serially.
+	// The result is the same regardless.
+	Workers int
+	// Mode is the rendering mode, ModeFill by default.
+	Mode Mode
+	// Edges are the ModeEdges parameters.
+	Edges EdgeOptions
+	// Fit are the ModeFit parameters.
+	Fit FitOptions
+	// Halftone is the ModeHalftone fill order, CenterOut by default.
+	Halftone FillOrder
+	// Filters are the preprocessing steps applied in order to the
+	// luminance before thresholding or detecting the edges.
+	Filters []Filter
 }
 
 // Convert the given image to a grayscale BUG one.
+// BUG images are re-thresholded in place when needed.
 func Convert(img image.Image, t Threshold) *Gray {
+	return ConvertWithOptions(img, &Options{Threshold: t})
+}
+
+// ConvertWithOptions converts the given image to a grayscale BUG one using
+// the given options. A nil o uses the DefaultThreshold.
+// BUG images are re-thresholded in place when needed. As they only retain
+// their grayscale, a Classifier sees the gray values rather than the
+// original colors, e.g. an HSVRange matches none of them: classify the
+// source image instead.
+// Other modes than ModeFill, or filters, always return a new image.
+func ConvertWithOptions(img image.Image, o *Options) *Gray {
+	if o == nil {
+		o = &Options{Threshold: DefaultThreshold}
+	}
+	if _, ok := img.(cellImage); !ok && o.hasAlphaOptions() {
+		return convertAlpha(img, o)
+	}
+	switch o.Mode {
+	case ModeEdges:
+		return convertEdges(img, o)
+	case ModeFit:
+		return convertFit(img, o)
+	case ModeHalftone:
+		return convertHalftone(img, o)
+	}
+	if len(o.Filters) > 0 {
+		return convertFiltered(img, o)
+	}
 	if g, ok := img.(*Gray); ok {
-		g.Threshold = t
+		dither := g.Dither
+		if o.Dither != NoDither {
+			dither = o.Dither
+		}
+		switch {
+		case o.Classifier != nil:
+			// Only the retained grayscale is left to classify.
+			g.Threshold, g.Dither, g.Classifier = o.Threshold, dither, o.Classifier
+			b := g.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					g.Set(x, y, g.Gray.GrayAt(x, y))
+				}
+			}
+		case g.Threshold != o.Threshold || g.Dither != dither || g.Classifier != nil:
+			g.Threshold, g.Dither, g.Classifier = o.Threshold, dither, nil
+			g.rethreshold(o.Workers)
+		}
 		return g
 	}
+	if b, ok := img.(*Bitmap); ok {
+		return ConvertWithOptions(b.Gray(), o)
+	}
 
-	g := NewGray(img.Bounds())
-	g.Threshold = t
-	draw.Draw(g, g.Bounds(), img, img.Bounds().Min, draw.Over)
+	g := newGray(img.Bounds(), o.Threshold)
+	g.Dither, g.Classifier = o.Dither, o.Classifier
+	// Using Src on the "empty" image yields the same result as compositing
+	// Over black: transparent pixels get their premultiplied color.
+	drawWorkers(g, g.Bounds(), img, img.Bounds().Min, o.Workers)
 	return g
 }
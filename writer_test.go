@@ -0,0 +1,168 @@
+package bug
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// stripes is a generated image, without any pixel buffer.
+type stripes struct {
+	rect image.Rectangle
+}
+
+func (s stripes) ColorModel() color.Model { return color.GrayModel }
+func (s stripes) Bounds() image.Rectangle { return s.rect }
+func (s stripes) At(x, y int) color.Color { return color.Gray{Y: uint8((x + y) * 16)} }
+
+// encodeContext encodes the given image using EncodeContext.
+func encodeContext(tb testing.TB, img image.Image, t Threshold) []byte {
+	tb.Helper()
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	enc.Threshold = t
+	requireNoError(tb, enc.EncodeContext(context.Background(), img), "EncodeContext.")
+	return buf.Bytes()
+}
+
+// Make sure the streaming encoder yields the same result as the regular one.
+func TestEncodeContext(t *testing.T) {
+	img, _, err := image.Decode(mustGetFile(t, "testdata/biplane.png"))
+	requireNoError(t, err, "Decode testdata image.")
+
+	gray16 := image.NewGray16(image.Rect(0, 0, 10, 10))
+	gray16.SetGray16(3, 3, color.Gray16{Y: 0}) // color.Black.
+	gray16.SetGray16(4, 4, color.Gray16{Y: 0xffff})
+	paletted := image.NewPaletted(image.Rect(-1, -1, 33, 17), color.Palette{color.Black, color.White, color.Gray{Y: 0x7f}})
+	for i := range paletted.Pix {
+		paletted.Pix[i] = uint8(i % 3)
+	}
+
+	r := image.Rect(-3, -7, 149, 201)
+	sources := map[string]image.Image{
+		"biplane":  img,
+		"gray":     newNoiseImage(func(r image.Rectangle) image.Image { return image.NewGray(r) }, r),
+		"rgba":     newNoiseImage(func(r image.Rectangle) image.Image { return image.NewRGBA(r) }, r),
+		"nrgba":    newNoiseImage(func(r image.Rectangle) image.Image { return image.NewNRGBA(r) }, r),
+		"gray16":   gray16,
+		"paletted": paletted,
+		"stripes":  stripes{rect: image.Rect(1, 2, 51, 43)},
+		"bug":      Convert(img, DefaultThreshold),
+		"bitmap":   Convert(img, DefaultThreshold).Bitmap().SubImage(image.Rect(5, 3, 100, 150)),
+	}
+	for name, src := range sources {
+		for _, threshold := range []Threshold{DefaultThreshold, DefaultThreshold.Inverse(), 200} {
+			expect := encodeWorkers(t, src, threshold, 1)
+			if actual := encodeContext(t, src, threshold); !bytes.Equal(expect, actual) {
+				t.Fatalf("Unexpected streaming encoding of %s with threshold %d.", name, threshold)
+			}
+		}
+	}
+}
+
+// Test the streaming encoder cancellation.
+func TestEncodeContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewEncoder(ioutil.Discard).EncodeContext(ctx, stripes{rect: image.Rect(0, 0, 10, 10)})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+}
+
+// Make sure the streaming encoder memory doesn't depend on the image height.
+func TestEncodeContextAllocs(t *testing.T) {
+	allocs := func(height int) float64 {
+		img := stripes{rect: image.Rect(0, 0, 200, height)}
+		enc := NewEncoder(ioutil.Discard)
+		return testing.AllocsPerRun(3, func() {
+			requireNoError(t, enc.EncodeContext(context.Background(), img), "EncodeContext.")
+		})
+	}
+	if small, large := allocs(8), allocs(40000); large > small {
+		t.Fatalf("Unexpected allocations for a large image: %v, small one: %v.", large, small)
+	}
+}
+
+// Test the encoder output options.
+func TestEncoderFormat(t *testing.T) {
+	const input = "⠀⠀⠀⠀\n⠀⣿⠁⠀\n⠀⠀⠀⠀\n"
+	img, err := Decode(strings.NewReader(input))
+	requireNoError(t, err, "Decode input.")
+
+	format := func(t *testing.T, img image.Image, setup func(e *Encoder), expect string) {
+		t.Helper()
+
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf)
+		setup(enc)
+		requireNoError(t, enc.Encode(img), "Encode.")
+		assertEqual(t, expect, buf.String(), "Unexpected output.")
+
+		buf.Reset()
+		requireNoError(t, enc.EncodeContext(context.Background(), img), "EncodeContext.")
+		assertEqual(t, expect, buf.String(), "Unexpected streaming output.")
+	}
+
+	t.Run("default", func(t *testing.T) { format(t, img, func(e *Encoder) {}, input) })
+	t.Run("crop", func(t *testing.T) { format(t, img, func(e *Encoder) { e.Crop = true }, "⣿⠁\n") })
+	t.Run("trim-right", func(t *testing.T) { format(t, img, func(e *Encoder) { e.TrimRight = true }, "⠀\n⠀⣿⠁\n⠀\n") })
+	t.Run("blank", func(t *testing.T) { format(t, img, func(e *Encoder) { e.Blank = ' ' }, "    \n ⣿⠁ \n    \n") })
+	t.Run("crlf", func(t *testing.T) {
+		format(t, img, func(e *Encoder) { e.CRLF = true }, "⠀⠀⠀⠀\r\n⠀⣿⠁⠀\r\n⠀⠀⠀⠀\r\n")
+	})
+	t.Run("margin", func(t *testing.T) {
+		format(t, img, func(e *Encoder) { e.Crop, e.Margin = true, 1 }, input)
+	})
+	t.Run("prefix", func(t *testing.T) {
+		format(t, img, func(e *Encoder) { e.Prefix = "// " }, "// ⠀⠀⠀⠀\n// ⠀⣿⠁⠀\n// ⠀⠀⠀⠀\n")
+	})
+	t.Run("all", func(t *testing.T) {
+		format(t, img, func(e *Encoder) {
+			e.Crop, e.TrimRight, e.Margin, e.Blank, e.Prefix, e.CRLF = true, true, 1, ' ', "// ", true
+		}, "//  \r\n//  ⣿⠁\r\n//  \r\n")
+	})
+	t.Run("empty", func(t *testing.T) {
+		empty := NewGray(image.Rect(0, 0, 8, 8))
+		format(t, empty, func(e *Encoder) { e.Crop = true }, "⠀\n")
+		format(t, empty, func(e *Encoder) { e.Crop, e.Margin = true, 1 }, "⠀⠀⠀\n⠀⠀⠀\n⠀⠀⠀\n")
+	})
+
+	// Lenient decoding should restore the image, without the trailing blank lines.
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	enc.TrimRight, enc.Blank, enc.CRLF = true, ' ', true
+	requireNoError(t, enc.Encode(img), "Encode.")
+	lenient, err := NewDecoder(buf).WithMode(DecodeLenient).Decode()
+	requireNoError(t, err, "Decode lenient.")
+	assertEqual(t, "⠀⠀⠀\n⠀⣿⠁\n", encodeString(t, lenient), "Unexpected lenient round trip.")
+
+	// Animations directives are formatted as well.
+	buf.Reset()
+	enc = NewEncoder(buf)
+	enc.Crop, enc.Prefix, enc.CRLF = true, "# ", true
+	requireNoError(t, enc.EncodeAll(&Animation{Image: []*Gray{img.(*Gray)}, Delay: []int{10}, LoopCount: 2}), "EncodeAll.")
+	assertEqual(t, "# ⣿⠁\r\n# ;delay=10\r\n# ;loop=2\r\n", buf.String(), "Unexpected formatted animation.")
+}
+
+func BenchmarkEncode(b *testing.B) {
+	img := stripes{rect: image.Rect(0, 0, 1000, 4000)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = NewEncoder(ioutil.Discard).WithWorkers(1).Encode(img)
+	}
+}
+
+func BenchmarkEncodeContext(b *testing.B) {
+	img := stripes{rect: image.Rect(0, 0, 1000, 4000)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = NewEncoder(ioutil.Discard).EncodeContext(context.Background(), img)
+	}
+}